@@ -0,0 +1,107 @@
+// Package apperror maps domain errors to RFC 7807 problem+json
+// responses with stable, machine-readable codes.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is an error meant for the client. Cause is logged but never sent.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []FieldError
+	Cause  error
+}
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field" example:"title"`
+	Code    string `json:"code" example:"required"`
+	Message string `json:"message" example:"title is required"`
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithStatus returns a copy of e answered with another HTTP status.
+func (e *Error) WithStatus(status int) *Error {
+	copied := *e
+	copied.Status = status
+	return &copied
+}
+
+// As returns the *Error in err's chain, if any.
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	ok := errors.As(err, &appErr)
+	return appErr, ok
+}
+
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+func Unauthorized(code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+func Forbidden(code, detail string) *Error {
+	return New(http.StatusForbidden, code, detail)
+}
+
+func NotFound(code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+func Conflict(code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+func PreconditionFailed(code, detail string) *Error {
+	return New(http.StatusPreconditionFailed, code, detail)
+}
+
+// Validation reports every invalid field of a request at once.
+func Validation(detail string, fields ...FieldError) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Detail: detail, Fields: fields}
+}
+
+// Internal hides err from the client behind a generic message.
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: "Internal server error.", Cause: err}
+}
+
+// Field codes of FieldError
+const (
+	FieldRequired  = "required"
+	FieldInvalid   = "invalid"
+	FieldUnknown   = "unknown"
+	FieldForbidden = "forbidden"
+	FieldType      = "type"
+)
+
+// Required reports a missing field
+func Required(field string) FieldError {
+	return FieldError{Field: field, Code: FieldRequired, Message: field + " is required"}
+}
+
+// Invalid reports a field with a bad value
+func Invalid(field, message string) FieldError {
+	return FieldError{Field: field, Code: FieldInvalid, Message: message}
+}
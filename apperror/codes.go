@@ -0,0 +1,42 @@
+package apperror
+
+// Stable error codes clients can rely on
+const (
+	CodeInternal             = "internal_error"
+	CodeValidationFailed     = "validation_failed"
+	CodeInvalidBody          = "invalid_body"
+	CodeInvalidQuery         = "invalid_query"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeRouteNotFound        = "route_not_found"
+	CodeInvalidBlogID        = "invalid_blog_id"
+	CodeBlogNotFound         = "blog_not_found"
+	CodeNotBlogAuthor        = "not_blog_author"
+	CodeInvalidContent       = "invalid_content"
+	CodeVersionConflict      = "version_conflict"
+	CodePreconditionRequired = "precondition_required"
+	CodeInvalidPatch         = "invalid_patch"
+	CodePatchTestFailed      = "patch_test_failed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUserExists           = "user_exists"
+	CodeUserNotFound         = "user_not_found"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeSessionRevoked       = "session_revoked"
+	CodeEmailTaken           = "email_taken"
+	CodeInvalidToken         = "invalid_token"
+	CodeTagConflict          = "tag_conflict"
+	CodeTagNotFound          = "tag_not_found"
+	CodeInvalidCommentID     = "invalid_comment_id"
+	CodeCommentNotFound      = "comment_not_found"
+	CodeCommentTooDeep       = "comment_too_deep"
+	CodeEditWindowClosed     = "edit_window_closed"
+	CodeNotCommentAuthor     = "not_comment_author"
+	CodeCommentStatusChanged = "comment_status_changed"
+	CodeInvalidListID        = "invalid_list_id"
+	CodeReadingListNotFound  = "reading_list_not_found"
+	CodeReadingListFull      = "reading_list_full"
+	CodeReadingListChanged   = "reading_list_changed"
+	CodeTagIsAlias           = "tag_is_alias"
+	CodeMailUnavailable      = "mail_unavailable"
+)
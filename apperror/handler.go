@@ -0,0 +1,72 @@
+package apperror
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const MIMEProblemJSON = "application/problem+json"
+
+// Problem is the RFC 7807 body of every error response.
+type Problem struct {
+	Type      string       `json:"type" example:"about:blank"`
+	Title     string       `json:"title" example:"Not Found"`
+	Status    int          `json:"status" example:"404"`
+	Detail    string       `json:"detail" example:"Blog not found."`
+	Instance  string       `json:"instance" example:"/api/v2/blogs/01J9Z3T6Q8X4V2M7N5K0R1B3CD"`
+	Code      string       `json:"code" example:"blog_not_found"`
+	RequestID string       `json:"request_id,omitempty" example:"0b5c2a8e-3f0e-4a57-9a59-0d1c2f3e4a5b"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	// Deprecated: same as Detail, kept for v1 clients
+	Message string `json:"message" example:"Blog not found."`
+}
+
+// Handler is the Fiber ErrorHandler. Handlers return errors and this
+// renders them as problem+json.
+func Handler(c *fiber.Ctx, err error) error {
+	appErr, ok := As(err)
+	if !ok {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			appErr = New(fiberErr.Code, fiberCode(fiberErr.Code), fiberErr.Message)
+		} else {
+			appErr = Internal(err)
+		}
+	}
+	requestID, _ := c.Locals("requestid").(string)
+	if appErr.Status >= http.StatusInternalServerError {
+		log.Printf("request %s %s %s failed: %v", requestID, c.Method(), c.Path(), err)
+	}
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(appErr.Status),
+		Status:    appErr.Status,
+		Detail:    appErr.Detail,
+		Instance:  c.OriginalURL(),
+		Code:      appErr.Code,
+		RequestID: requestID,
+		Errors:    appErr.Fields,
+		Message:   appErr.Detail,
+	}
+	return c.Status(appErr.Status).JSON(problem, MIMEProblemJSON)
+}
+
+func fiberCode(status int) string {
+	switch status {
+	case fiber.StatusNotFound:
+		return CodeRouteNotFound
+	case fiber.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidBody
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"time"
+)
+
+// AccountBlogPolicy is what happens to a deleted account's blogs when the
+// request doesn't say: delete, anonymize (default) or transfer.
+func AccountBlogPolicy() string {
+	policy := os.Getenv("ACCOUNT_BLOG_POLICY")
+	if slices.Contains([]string{"delete", "anonymize", "transfer"}, policy) {
+		return policy
+	}
+	return "anonymize"
+}
+
+// AccountDeletionRetryInterval is how often account deletions that failed
+// part way are retried, set with ACCOUNT_DELETION_RETRY_INTERVAL (default
+// 10m).
+func AccountDeletionRetryInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_RETRY_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 10 * time.Minute
+	}
+	return interval
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultCommentEditWindow = 15 * time.Minute
+
+// CommentEditWindow is how long after posting a comment can be edited,
+// set with COMMENT_EDIT_WINDOW as a Go duration such as 15m.
+func CommentEditWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("COMMENT_EDIT_WINDOW"))
+	if err != nil || window < 0 {
+		return defaultCommentEditWindow
+	}
+	return window
+}
+
+// CommentModeration is the moderation mode of blogs that don't set one,
+// pre or post (default), set with COMMENT_MODERATION.
+func CommentModeration() string {
+	if os.Getenv("COMMENT_MODERATION") == "pre" {
+		return "pre"
+	}
+	return "post"
+}
+
+// SpamThreshold is the spam score from which comments are filed as spam,
+// set with SPAM_THRESHOLD between 0 and 1 (default 0.7). Comments scoring
+// half of it wait for a moderator.
+func SpamThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("SPAM_THRESHOLD"), 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		return 0.7
+	}
+	return threshold
+}
+
+// SpamBlocklist lists extra words and phrases that mark a comment as spam,
+// set with SPAM_BLOCKLIST as a comma-separated list.
+func SpamBlocklist() []string {
+	var words []string
+	for _, word := range strings.Split(os.Getenv("SPAM_BLOCKLIST"), ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
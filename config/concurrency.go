@@ -0,0 +1,14 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// RequireIfMatch reports whether blog updates and deletes must send an
+// If-Match header, set with REQUIRE_IF_MATCH. Without it the header is
+// optional but still checked when present.
+func RequireIfMatch() bool {
+	required, _ := strconv.ParseBool(os.Getenv("REQUIRE_IF_MATCH"))
+	return required
+}
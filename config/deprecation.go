@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+var (
+	defaultV1DeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	defaultV1SunsetAt     = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// V1Deprecation returns when /api/v1 was deprecated and when it will be
+// removed, overridable with API_V1_DEPRECATED_AT and API_V1_SUNSET_AT
+// (RFC 3339).
+func V1Deprecation() (time.Time, time.Time) {
+	return envTime("API_V1_DEPRECATED_AT", defaultV1DeprecatedAt), envTime("API_V1_SUNSET_AT", defaultV1SunsetAt)
+}
+
+func envTime(key string, fallback time.Time) time.Time {
+	value, err := time.Parse(time.RFC3339, os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
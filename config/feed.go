@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultFeedTimelineThreshold = 50
+	defaultFeedTimelineSize      = 1000
+	defaultFeedTimelineTTL       = 24 * time.Hour
+)
+
+// FeedTimelineThreshold is the number of follows above which a user's
+// feed is served from a precomputed Redis timeline instead of being
+// queried on every read, set with FEED_TIMELINE_THRESHOLD.
+func FeedTimelineThreshold() int {
+	threshold, err := strconv.Atoi(os.Getenv("FEED_TIMELINE_THRESHOLD"))
+	if err != nil || threshold < 0 {
+		return defaultFeedTimelineThreshold
+	}
+	return threshold
+}
+
+// FeedTimelineSize is how many posts a precomputed timeline keeps, set
+// with FEED_TIMELINE_SIZE. Older pages are queried like small feeds.
+func FeedTimelineSize() int {
+	size, err := strconv.Atoi(os.Getenv("FEED_TIMELINE_SIZE"))
+	if err != nil || size < 1 {
+		return defaultFeedTimelineSize
+	}
+	return size
+}
+
+// FeedTimelineTTL is how long an unread timeline is kept before it is
+// rebuilt, set with FEED_TIMELINE_TTL as a Go duration such as 24h.
+func FeedTimelineTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("FEED_TIMELINE_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultFeedTimelineTTL
+	}
+	return ttl
+}
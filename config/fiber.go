@@ -3,6 +3,8 @@ package config
 import (
 	"encoding/json"
 
+	"inkinkink111/go-blog-management/apperror"
+
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -11,5 +13,7 @@ func NewFiberConfig() fiber.Config {
 		AppName:     "Go Blog Management",
 		JSONEncoder: json.Marshal,
 		JSONDecoder: json.Unmarshal,
+		// Every error is answered as application/problem+json
+		ErrorHandler: apperror.Handler,
 	}
 }
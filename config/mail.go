@@ -0,0 +1,13 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// MailLog reports whether emails are written to the log instead of sent,
+// for development without a mail provider, set with MAIL_LOG.
+func MailLog() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("MAIL_LOG"))
+	return enabled
+}
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+const (
+	DefaultPageLimit = 10
+	defaultMaxLimit  = 100
+)
+
+// MaxPageLimit is the largest page size list endpoints accept, set with
+// MAX_PAGE_LIMIT.
+func MaxPageLimit() int {
+	limit, err := strconv.Atoi(os.Getenv("MAX_PAGE_LIMIT"))
+	if err != nil || limit < 1 {
+		return defaultMaxLimit
+	}
+	return limit
+}
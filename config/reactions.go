@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultReactions = "like,love,laugh,wow,sad"
+
+// Reactions is the set readers can react with, set with REACTIONS as a
+// comma-separated list of names. like is always included.
+func Reactions() []string {
+	list := os.Getenv("REACTIONS")
+	if strings.TrimSpace(list) == "" {
+		list = defaultReactions
+	}
+	reactions := []string{"like"}
+	for _, reaction := range strings.Split(list, ",") {
+		reaction = strings.ToLower(strings.TrimSpace(reaction))
+		if reaction != "" && !slices.Contains(reactions, reaction) {
+			reactions = append(reactions, reaction)
+		}
+	}
+	return reactions
+}
+
+// ReactionFlushInterval is how often reaction counts are copied from
+// Redis to MongoDB, set with REACTION_FLUSH_INTERVAL (default 1m).
+func ReactionFlushInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("REACTION_FLUSH_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
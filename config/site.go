@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// SiteURL is the public base URL used in links sent to users, set with
+// SITE_URL.
+func SiteURL() string {
+	if url := os.Getenv("SITE_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// SiteName titles the site's feeds and sitemap, set with SITE_NAME.
+func SiteName() string {
+	if name := os.Getenv("SITE_NAME"); name != "" {
+		return name
+	}
+	return "Blog"
+}
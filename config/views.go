@@ -0,0 +1,29 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+const defaultViewDedupWindow = 30 * time.Minute
+
+// ViewDedupWindow is how long repeated reads of a blog by the same visitor
+// count as one view, set with VIEW_DEDUP_WINDOW as a Go duration such as
+// 30m.
+func ViewDedupWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("VIEW_DEDUP_WINDOW"))
+	if err != nil || window <= 0 {
+		return defaultViewDedupWindow
+	}
+	return window
+}
+
+// ViewFlushInterval is how often view counts are copied from Redis to
+// MongoDB, set with VIEW_FLUSH_INTERVAL (default 1m).
+func ViewFlushInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("VIEW_FLUSH_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
@@ -0,0 +1,43 @@
+// Package mail sends transactional emails. No provider is bundled: sending
+// fails until one is plugged in as Default, or LogSender is enabled for
+// development.
+package mail
+
+import (
+	"errors"
+	"log"
+	"regexp"
+)
+
+// ErrNotConfigured is returned when no sender was set up
+var ErrNotConfigured = errors.New("mail: no sender configured")
+
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// Default is used by services, replace it to plug in a real provider
+var Default Sender = disabled{}
+
+// Configured reports whether Default can send mail
+func Configured() bool {
+	_, off := Default.(disabled)
+	return !off
+}
+
+type disabled struct{}
+
+func (disabled) Send(to, subject, body string) error {
+	return ErrNotConfigured
+}
+
+var secrets = regexp.MustCompile(`(?i)(token[=:]\s*)\S+`)
+
+// LogSender writes emails to the log instead of sending them, for
+// development. Tokens are redacted so logs never hold usable secrets.
+type LogSender struct{}
+
+func (LogSender) Send(to, subject, body string) error {
+	log.Printf("mail to %s: %s\n%s", to, subject, secrets.ReplaceAllString(body, "${1}[redacted]"))
+	return nil
+}
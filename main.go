@@ -21,12 +21,17 @@ import (
 	"inkinkink111/go-blog-management/config"
 	"inkinkink111/go-blog-management/db"
 	_ "inkinkink111/go-blog-management/docs" // This will be generated
+	"inkinkink111/go-blog-management/mail"
+	"inkinkink111/go-blog-management/repositories"
 	"inkinkink111/go-blog-management/routes"
+	"inkinkink111/go-blog-management/search"
+	"inkinkink111/go-blog-management/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/swagger"
 	"github.com/joho/godotenv"
 )
@@ -38,7 +43,10 @@ func main() {
 	}
 	//
 	app := fiber.New(config.NewFiberConfig())
+	// Request ids are echoed in X-Request-ID and in error responses
+	app.Use(requestid.New())
 	app.Use(logger.New(logger.Config{
+		Format:     "[${time}] ${locals:requestid} ${status} - ${latency} ${method} ${path}\n",
 		TimeFormat: "02-Jan-2006 15:04:05",
 		TimeZone:   "Asia/Bangkok",
 	}))
@@ -50,9 +58,33 @@ func main() {
 	db.ConnectMongo()
 	db.ConnectRedis()
 
+	// Migrate first, older documents may break the unique indexes
+	if err := repositories.Migrate(); err != nil {
+		log.Fatal("Failed to migrate MongoDB documents", err)
+	}
+	if err := repositories.EnsureIndexes(); err != nil {
+		log.Fatal("Failed to create MongoDB indexes", err)
+	}
+	if search.UseMemoryIndex() {
+		if err := search.LoadMemoryIndex(); err != nil {
+			log.Fatal("Failed to build search index", err)
+		}
+		log.Println("Using in-memory search index")
+	}
+
 	// mongoClient := db.NewMongoClient(10)
 	// userRepo := repositories.NewUsersDB(mongoClient)
 
+	if config.MailLog() {
+		mail.Default = mail.LogSender{}
+		log.Println("Logging emails instead of sending them")
+	}
+
+	services.StartReactionFlusher(config.ReactionFlushInterval())
+	services.StartViewCounter(config.ViewFlushInterval())
+	services.StartRelatedWorker()
+	services.StartAccountDeletions(config.AccountDeletionRetryInterval())
+
 	routes.SetupRoutes(app)
 
 	app.Listen(":3000")
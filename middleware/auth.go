@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/repositories"
 	"inkinkink111/go-blog-management/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -10,19 +12,48 @@ func Authenticate(c *fiber.Ctx) error {
 	header := c.Get("Authorization")
 
 	if header == "" || header == "Bearer " {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
+		return apperror.Unauthorized(apperror.CodeUnauthorized, "Unauthorized.")
+	}
+
+	return authenticate(c, header)
+}
+
+// OptionalAuthenticate lets anonymous requests through, but a token that
+// is sent must be valid
+func OptionalAuthenticate(c *fiber.Ctx) error {
+	header := c.Get("Authorization")
+
+	if header == "" {
+		return c.Next()
+	}
+
+	return authenticate(c, header)
+}
+
+func authenticate(c *fiber.Ctx, header string) error {
+	if len(header) < 7 {
+		return apperror.Unauthorized(apperror.CodeUnauthorized, "Unauthorized.")
 	}
 
 	token := header[7:]
 
-	userId, err := utils.VerifyToken(token)
+	userId, issuedAt, err := utils.VerifyToken(token)
 
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
+		return apperror.Unauthorized(apperror.CodeUnauthorized, "Unauthorized.")
 	}
 
 	if userId == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Unauthorized"})
+		return apperror.Unauthorized(apperror.CodeUnauthorized, "Unauthorized.")
+	}
+
+	// Reject tokens of deleted users and revoked sessions
+	validAfter, exists, err := repositories.NewUserRepository().TokensValidAfter(userId)
+	if err != nil {
+		return err
+	}
+	if !exists || issuedAt.Before(validAfter) {
+		return apperror.Unauthorized(apperror.CodeSessionRevoked, "Session expired, log in again.")
 	}
 
 	c.Locals("userId", userId)
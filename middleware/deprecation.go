@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Deprecated marks every response as coming from a deprecated API
+// version (RFC 9745 Deprecation, RFC 8594 Sunset) and links to its
+// successor.
+func Deprecated(deprecatedAt, sunsetAt time.Time, successor string) fiber.Handler {
+	deprecation := fmt.Sprintf("@%d", deprecatedAt.Unix())
+	sunset := sunsetAt.UTC().Format(http.TimeFormat)
+	link := fmt.Sprintf(`<%s>; rel="successor-version"`, successor)
+	return func(c *fiber.Ctx) error {
+		c.Set("Deprecation", deprecation)
+		c.Set("Sunset", sunset)
+		c.Append(fiber.HeaderLink, link)
+		return c.Next()
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"slices"
+
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/repositories"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireRole only lets through authenticated users holding one of the
+// given roles. The role is read from the database so revoking it takes
+// effect without waiting for the token to expire.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userId, _ := c.Locals("userId").(string)
+		if userId == "" {
+			return apperror.Unauthorized(apperror.CodeUnauthorized, "Unauthorized.")
+		}
+
+		user, err := repositories.NewUserRepository().GetUserByUserID(userId)
+
+		if err != nil {
+			return err
+		}
+
+		if user == nil || !slices.Contains(roles, user.Role) {
+			return apperror.Forbidden(apperror.CodeForbidden, "Forbidden.")
+		}
+
+		c.Locals("role", user.Role)
+
+		return c.Next()
+	}
+}
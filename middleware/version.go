@@ -0,0 +1,12 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// APIVersion records which API version a route belongs to, for handlers
+// shared by both versions
+func APIVersion(version string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals("apiVersion", version)
+		return c.Next()
+	}
+}
@@ -6,14 +6,102 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Blog statuses. Drafts are only visible to their author, blogs stored
+// before drafts existed have no status and are published.
+const (
+	BlogPublished = "published"
+	BlogDraft     = "draft"
+)
+
 type Blog struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	BlogID    string             `json:"blog_id" bson:"blog_id" example:"1234567890"`
-	Title     string             `json:"title" bson:"title" example:"My Blog Title"`
-	Content   string             `json:"content" bson:"content" example:"Blog content"`
-	Slug      string             `json:"slug" bson:"slug" example:"my-blog-title"`
-	CreatedAt time.Time          `json:"created_at" bson:"created_at" example:"2021-01-01T00:00:00Z"`
-	Tags      []string           `json:"tags" bson:"tags" example:"golang,redis"`
-	AuthorID  string             `json:"author_id" bson:"author_id" example:"1234567890"`
-	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at" example:"2021-01-01T00:00:00Z"`
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	BlogID      string             `json:"blog_id" bson:"blog_id" example:"01J9Z3T6Q8X4V2M7N5K0R1B3CD"`
+	Title       string             `json:"title" bson:"title" example:"My Blog Title"`
+	Content     string             `json:"content" bson:"content" example:"Blog content"`
+	ContentHTML string             `json:"content_html" bson:"content_html" example:"<p>Blog content</p>"`
+	TOC         []TOCEntry         `json:"toc" bson:"toc"`
+	Excerpt     string             `json:"excerpt" bson:"excerpt" example:"Blog content"`
+	WordCount   int                `json:"word_count" bson:"word_count" example:"2"`
+	ReadingTime int                `json:"reading_time" bson:"reading_time" example:"1"`
+	Slug        string             `json:"slug" bson:"slug" example:"my-blog-title"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at" example:"2021-01-01T00:00:00Z"`
+	Tags        []string           `json:"tags" bson:"tags" example:"golang,redis"`
+	AuthorID    string             `json:"author_id" bson:"author_id" example:"1234567890"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at" example:"2021-01-01T00:00:00Z"`
+	Popularity  int64              `json:"popularity" bson:"popularity" example:"0"`
+	Version     int64              `json:"version" bson:"version" example:"1"`
+	// Visible comments, not counted as an edit
+	CommentCount int64 `json:"comment_count" bson:"comment_count" example:"3"`
+	// Reaction counts, persisted from Redis periodically
+	Reactions map[string]int64 `json:"reactions" bson:"reactions,omitempty"`
+	LikeCount int64            `json:"like_count" bson:"like_count" example:"12"`
+	// Which reactions the current user left, only set when logged in
+	MyReactions map[string]bool `json:"my_reactions,omitempty" bson:"-"`
+	// Empty uses the COMMENT_MODERATION default
+	CommentModeration string `json:"comment_moderation,omitempty" bson:"comment_moderation,omitempty" example:"pre"`
+	Status            string `json:"status" bson:"status,omitempty" example:"published"`
+	// Only set when the author is embedded
+	Author *AuthorSummary `json:"author,omitempty" bson:"-"`
+}
+
+// BlogFields are the parts of a blog its author can edit
+type BlogFields struct {
+	Title             string   `json:"title" example:"My Blog Title"`
+	Content           string   `json:"content" example:"Blog content"`
+	Tags              []string `json:"tags" example:"golang,redis"`
+	CommentModeration string   `json:"comment_moderation" example:"pre"`
+	Status            string   `json:"status" example:"published"`
+}
+
+func (b *Blog) Fields() BlogFields {
+	return BlogFields{
+		Title:             b.Title,
+		Content:           b.Content,
+		Tags:              b.Tags,
+		CommentModeration: b.CommentModeration,
+		Status:            b.Status,
+	}
+}
+
+// Published reports whether readers other than the author can see the blog
+func (b *Blog) Published() bool {
+	return b.Status != BlogDraft
+}
+
+// BlogSummary is the compact projection returned by list endpoints
+type BlogSummary struct {
+	BlogID       string    `json:"blog_id" example:"01J9Z3T6Q8X4V2M7N5K0R1B3CD"`
+	Title        string    `json:"title" example:"My Blog Title"`
+	Slug         string    `json:"slug" example:"my-blog-title"`
+	Excerpt      string    `json:"excerpt" example:"Blog content"`
+	WordCount    int       `json:"word_count" example:"2"`
+	ReadingTime  int       `json:"reading_time" example:"1"`
+	Tags         []string  `json:"tags" example:"golang,redis"`
+	AuthorID     string    `json:"author_id" example:"1234567890"`
+	CreatedAt    time.Time `json:"created_at" example:"2021-01-01T00:00:00Z"`
+	UpdatedAt    time.Time `json:"updated_at" example:"2021-01-01T00:00:00Z"`
+	Popularity   int64     `json:"popularity" example:"0"`
+	CommentCount int64     `json:"comment_count" example:"3"`
+	LikeCount    int64     `json:"like_count" example:"12"`
+	// Only set when the author is embedded
+	Author *AuthorSummary `json:"author,omitempty"`
+}
+
+func (b *Blog) Summary() BlogSummary {
+	return BlogSummary{
+		BlogID:       b.BlogID,
+		Title:        b.Title,
+		Slug:         b.Slug,
+		Excerpt:      b.Excerpt,
+		WordCount:    b.WordCount,
+		ReadingTime:  b.ReadingTime,
+		Tags:         b.Tags,
+		AuthorID:     b.AuthorID,
+		CreatedAt:    b.CreatedAt,
+		UpdatedAt:    b.UpdatedAt,
+		Popularity:   b.Popularity,
+		LikeCount:    b.LikeCount,
+		CommentCount: b.CommentCount,
+		Author:       b.Author,
+	}
 }
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxCommentDepth is how deep replies can nest, top-level comments are 0
+const MaxCommentDepth = 5
+
+// Comment statuses, only approved comments are shown and counted.
+// Comments stored before moderation have no status and count as approved.
+const (
+	CommentPending  = "pending"
+	CommentApproved = "approved"
+	CommentRejected = "rejected"
+	CommentSpam     = "spam"
+)
+
+// Blog comment moderation modes
+const (
+	// Comments wait in the moderation queue until approved
+	ModerationPre = "pre"
+	// Comments are shown at once and can be removed afterwards
+	ModerationPost = "post"
+)
+
+type Comment struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	CommentID string             `json:"comment_id" bson:"comment_id" example:"01J9Z4A1B2C3D4E5F6G7H8J9KM"`
+	BlogID    string             `json:"blog_id" bson:"blog_id" example:"01J9Z3T6Q8X4V2M7N5K0R1B3CD"`
+	// ParentID is empty for top-level comments
+	ParentID string `json:"parent_id,omitempty" bson:"parent_id,omitempty" example:"01J9Z49ZQ8X4V2M7N5K0R1B3CD"`
+	// ThreadID is the id of the top-level comment of the thread
+	ThreadID  string     `json:"thread_id" bson:"thread_id" example:"01J9Z49ZQ8X4V2M7N5K0R1B3CD"`
+	Depth     int        `json:"depth" bson:"depth" example:"1"`
+	AuthorID  string     `json:"author_id,omitempty" bson:"author_id" example:"1234567890"`
+	Content   string     `json:"content" bson:"content" example:"Great post!"`
+	Deleted   bool       `json:"deleted" bson:"deleted" example:"false"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at" example:"2021-01-01T00:00:00Z"`
+	EditedAt  *time.Time `json:"edited_at,omitempty" bson:"edited_at,omitempty" example:"2021-01-01T00:05:00Z"`
+	Status    string     `json:"status" bson:"status,omitempty" example:"approved"`
+	// Only shown to moderators
+	SpamScore   float64  `json:"spam_score,omitempty" bson:"spam_score" example:"0.25"`
+	SpamReasons []string `json:"spam_reasons,omitempty" bson:"spam_reasons,omitempty" example:"links"`
+	// Only set in responses
+	Author  *AuthorSummary `json:"author,omitempty" bson:"-"`
+	Replies []*Comment     `json:"replies" bson:"-"`
+}
+
+type CreateCommentRequest struct {
+	Content  string `json:"content" example:"Great post!" validate:"required,max=5000"`
+	ParentID string `json:"parent_id" example:"01J9Z49ZQ8X4V2M7N5K0R1B3CD"`
+}
+
+type UpdateCommentRequest struct {
+	Content string `json:"content" example:"Great post, thanks!" validate:"required,max=5000"`
+}
+
+// Approved reports whether the comment is visible to readers
+func (c *Comment) Approved() bool {
+	return c.Status == "" || c.Status == CommentApproved
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// What a user can follow
+const (
+	FollowUser = "user"
+	FollowTag  = "tag"
+)
+
+// Follow is one user following an author or a tag
+type Follow struct {
+	ID         primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	FollowerID string             `json:"follower_id" bson:"follower_id"`
+	Type       string             `json:"type" bson:"type"`
+	Target     string             `json:"target" bson:"target"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Following is everything a user follows, oldest follow first
+type Following struct {
+	Users []AuthorSummary `json:"users"`
+	Tags  []string        `json:"tags" example:"golang,redis"`
+}
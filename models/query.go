@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+const (
+	TagModeAny = "any"
+	TagModeAll = "all"
+)
+
+const (
+	SortNewest  = "newest"
+	SortOldest  = "oldest"
+	SortUpdated = "updated"
+	SortTitle   = "title"
+	// Counted views, since view counting started. Older views were never
+	// recorded, so blogs start at 0 and tie on blog_id until read.
+	SortPopularity = "popularity"
+	SortLikes      = "likes"
+)
+
+var BlogSorts = []string{SortNewest, SortOldest, SortUpdated, SortTitle, SortPopularity, SortLikes}
+
+// BlogListQuery holds the parsed filters of a blog list request
+type BlogListQuery struct {
+	Page         int
+	Limit        int
+	AuthorID     string
+	Tags         []string
+	ExcludedTags []string
+	TagMode      string
+	Sort         string
+	Cursor       *ListCursor
+	WithContent  bool
+	EmbedAuthor  bool
+}
+
+// ListCursor marks a position in a sorted blog list. Before selects the
+// page preceding the position instead of the one following it.
+type ListCursor struct {
+	Sort       string    `json:"s"`
+	Time       time.Time `json:"t,omitempty"`
+	Title      string    `json:"n,omitempty"`
+	Popularity int64     `json:"p,omitempty"`
+	Likes      int64     `json:"l,omitempty"`
+	BlogID     string    `json:"id"`
+	Before     bool      `json:"b,omitempty"`
+}
+
+// CursorFor returns the cursor positioned on blog for the given sort.
+func CursorFor(blog *Blog, sort string, before bool) ListCursor {
+	cursor := ListCursor{Sort: sort, BlogID: blog.BlogID, Before: before}
+	switch sort {
+	case SortUpdated:
+		cursor.Time = blog.UpdatedAt
+	case SortTitle:
+		cursor.Title = blog.Title
+	case SortPopularity:
+		cursor.Popularity = blog.Popularity
+	case SortLikes:
+		cursor.Likes = blog.LikeCount
+	default:
+		cursor.Time = blog.CreatedAt
+	}
+	return cursor
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReactionLike is always part of the reaction set, the likes sort counts it
+const ReactionLike = "like"
+
+// Reaction is one user's reaction to a blog
+type Reaction struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	BlogID    string             `json:"blog_id" bson:"blog_id"`
+	UserID    string             `json:"user_id" bson:"user_id"`
+	Reaction  string             `json:"reaction" bson:"reaction"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// BlogReactions are a blog's reaction counts and the current user's flags
+type BlogReactions struct {
+	Reactions   map[string]int64 `json:"reactions" example:"like:12,love:3"`
+	MyReactions map[string]bool  `json:"my_reactions" example:"like:true,love:false"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxReadingListItems caps a reading list, its blog ids are one array
+const MaxReadingListItems = 500
+
+// Bookmark is a blog a user saved for later
+type Bookmark struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	UserID    string             `json:"user_id" bson:"user_id"`
+	BlogID    string             `json:"blog_id" bson:"blog_id"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// ReadingList is a named, ordered list of blogs. Public lists can be read
+// by anyone through their share link.
+type ReadingList struct {
+	ID          primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	ListID      string             `json:"list_id" bson:"list_id" example:"01J9Z5B1C2D3E4F5G6H7J8K9MN"`
+	UserID      string             `json:"user_id" bson:"user_id" example:"1234567890"`
+	Name        string             `json:"name" bson:"name" example:"Go deep dives"`
+	Description string             `json:"description" bson:"description" example:"Posts to read this weekend"`
+	Public      bool               `json:"public" bson:"public" example:"true"`
+	BlogIDs     []string           `json:"blog_ids" bson:"blog_ids" example:"01J9Z3T6Q8X4V2M7N5K0R1B3CD"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at" example:"2021-01-01T00:00:00Z"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at" example:"2021-01-01T00:00:00Z"`
+	// Kept when the list is made private so old links work again once public
+	ShareToken string `json:"-" bson:"share_token,omitempty"`
+	// Only set in responses, for public lists
+	ShareURL string `json:"share_url,omitempty" bson:"-" example:"http://localhost:3000/lists/shared/3q2-7wEx"`
+}
+
+// SharedReadingList is a public list as shown through its share link,
+// without its owner or the ids of blogs readers can't see
+type SharedReadingList struct {
+	ListID      string    `json:"list_id" example:"01J9Z5B1C2D3E4F5G6H7J8K9MN"`
+	Name        string    `json:"name" example:"Go deep dives"`
+	Description string    `json:"description" example:"Posts to read this weekend"`
+	CreatedAt   time.Time `json:"created_at" example:"2021-01-01T00:00:00Z"`
+	UpdatedAt   time.Time `json:"updated_at" example:"2021-01-01T00:00:00Z"`
+	ShareURL    string    `json:"share_url" example:"http://localhost:3000/lists/shared/3q2-7wEx"`
+}
+
+// Shared returns the list as shown through its share link
+func (l *ReadingList) Shared() SharedReadingList {
+	return SharedReadingList{
+		ListID:      l.ListID,
+		Name:        l.Name,
+		Description: l.Description,
+		CreatedAt:   l.CreatedAt,
+		UpdatedAt:   l.UpdatedAt,
+		ShareURL:    l.ShareURL,
+	}
+}
+
+// Fields are the parts of a list its owner can edit
+func (l *ReadingList) Fields() ReadingListRequest {
+	return ReadingListRequest{Name: l.Name, Description: l.Description, Public: l.Public}
+}
+
+// ReadingListRequest creates a list, or is the result of a merge patch
+// over Fields when updating one
+type ReadingListRequest struct {
+	Name        string `json:"name" example:"Go deep dives" validate:"required,max=100"`
+	Description string `json:"description" example:"Posts to read this weekend" validate:"max=500"`
+	Public      bool   `json:"public" example:"false"`
+}
+
+type ReorderReadingListRequest struct {
+	BlogIDs []string `json:"blog_ids" example:"01J9Z3T6Q8X4V2M7N5K0R1B3CD" validate:"max=500"`
+}
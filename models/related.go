@@ -0,0 +1,8 @@
+package models
+
+// RelatedBlog is a blog summary with its similarity to another blog,
+// between 0 and 1
+type RelatedBlog struct {
+	BlogSummary
+	RelatedScore float64 `json:"related_score" example:"0.42"`
+}
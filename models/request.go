@@ -0,0 +1,76 @@
+package models
+
+// Request bodies only hold what clients may set. Server-owned fields like
+// author_id or user_id are rejected when parsing.
+
+type RegisterRequest struct {
+	Email    string `json:"email" example:"jane@example.com" validate:"required,email,max=254"`
+	Password string `json:"password" example:"correct-horse-battery" validate:"required,min=8,max=72"`
+	Name     string `json:"name" example:"Jane Doe" validate:"required,max=100"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" example:"jane@example.com" validate:"required,email"`
+	Password string `json:"password" example:"correct-horse-battery" validate:"required"`
+}
+
+type CreateBlogRequest struct {
+	Title   string   `json:"title" example:"My Blog Title" validate:"required,max=200"`
+	Content string   `json:"content" example:"Blog content" validate:"required,max=100000"`
+	Tags    []string `json:"tags" example:"golang,redis" validate:"required,min=1,max=10,dive,tag"`
+	// Moderate comments before (pre) or after (post) they are shown
+	CommentModeration string `json:"comment_moderation" example:"pre" validate:"omitempty,oneof=pre post"`
+	// Drafts are only visible to their author, published is the default
+	Status string `json:"status" example:"published" validate:"omitempty,oneof=draft published"`
+}
+
+func (r *CreateBlogRequest) Fields() BlogFields {
+	return BlogFields{Title: r.Title, Content: r.Content, Tags: r.Tags, CommentModeration: r.CommentModeration, Status: r.Status}
+}
+
+// UpdateBlogRequest replaces a blog's editable fields, it is also the
+// document a PATCH must produce.
+type UpdateBlogRequest struct {
+	Title   string   `json:"title" example:"My Blog Title" validate:"required,max=200"`
+	Content string   `json:"content" example:"Blog content" validate:"required,max=100000"`
+	Tags    []string `json:"tags" example:"golang,redis" validate:"required,min=1,max=10,dive,tag"`
+	// Moderate comments before (pre) or after (post) they are shown, left
+	// unchanged when omitted
+	CommentModeration string `json:"comment_moderation" example:"pre" validate:"omitempty,oneof=pre post"`
+	// Drafts are only visible to their author, left unchanged when omitted
+	Status string `json:"status" example:"published" validate:"omitempty,oneof=draft published"`
+}
+
+func (r *UpdateBlogRequest) Fields() BlogFields {
+	return BlogFields{Title: r.Title, Content: r.Content, Tags: r.Tags, CommentModeration: r.CommentModeration, Status: r.Status}
+}
+
+// UpdateProfileRequest is the profile a PATCH /me must produce
+type UpdateProfileRequest struct {
+	DisplayName string            `json:"display_name" example:"Jane Doe" validate:"max=100"`
+	Bio         string            `json:"bio" example:"Writes about Go and Redis." validate:"max=500"`
+	AvatarURL   string            `json:"avatar_url" example:"https://example.com/jane.png" validate:"omitempty,http_url,max=2048"`
+	SocialLinks map[string]string `json:"social_links" validate:"max=10,dive,keys,required,max=30,endkeys,required,http_url,max=2048"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" example:"correct-horse-battery" validate:"required"`
+	NewPassword     string `json:"new_password" example:"battery-staple-horse" validate:"required,min=8,max=72"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" example:"jane@example.org" validate:"required,email,max=254"`
+	Password string `json:"password" example:"correct-horse-battery" validate:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" example:"q3Jx0k3v6m0bq9y8Zb2gk1Q4mV8e5aY7dW3nT2sR1pU" validate:"required"`
+}
+
+// DeleteAccountRequest chooses what happens to the account's blogs, the
+// server default is used when blogs is empty.
+type DeleteAccountRequest struct {
+	Password   string `json:"password" example:"correct-horse-battery" validate:"required"`
+	Blogs      string `json:"blogs" example:"transfer" validate:"omitempty,oneof=delete anonymize transfer"`
+	TransferTo string `json:"transfer_to" example:"6f1c2a9e-5d3b-4c8a-9f0e-1b2c3d4e5f60"`
+}
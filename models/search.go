@@ -0,0 +1,20 @@
+package models
+
+type SearchResult struct {
+	BlogSummary
+	Score          float64 `json:"score" example:"1.5"`
+	TitleHighlight string  `json:"title_highlight" example:"My <mark>Blog</mark> Title"`
+	Snippet        string  `json:"snippet" example:"…some <mark>blog</mark> content…"`
+}
+
+type SearchResponse struct {
+	Message string `json:"message" example:"Search blogs successfully."`
+	Data    struct {
+		Results    []SearchResult `json:"results"`
+		Query      string         `json:"query" example:"golang \"error handling\""`
+		Page       int            `json:"page" example:"1"`
+		Limit      int            `json:"limit" example:"10"`
+		TotalPages int64          `json:"total_pages" example:"1"`
+		TotalItem  int64          `json:"total_item" example:"1"`
+	} `json:"data"`
+}
@@ -0,0 +1,195 @@
+package models_test
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/models"
+)
+
+// JSON names that must never appear in a response
+var sensitiveField = regexp.MustCompile(`(?i)(password|passwd|hash|salt|secret|token|otp|verification)`)
+
+// The access token returned by login is the point of that endpoint
+var allowedFields = map[string]bool{"token": true}
+
+func isSensitive(name string) bool {
+	return sensitiveField.MatchString(name) && !allowedFields[name]
+}
+
+// Every type handlers put in a response body
+var responseTypes = []any{
+	models.ResponseMsg{},
+	models.ResponseData{},
+	models.Blog{},
+	models.BlogSummary{},
+	models.TOCEntry{},
+	models.SearchResult{},
+	models.Tag{},
+	models.UserProfile{},
+	models.AuthorSummary{},
+	models.Account{},
+	models.Comment{},
+	models.BlogReactions{},
+	models.ReadingList{},
+	models.SharedReadingList{},
+	models.Follow{},
+	models.Following{},
+	models.Analytics{},
+	models.TrendingBlog{},
+	models.RelatedBlog{},
+	models.CreateBlogSuccess{},
+	models.GetAllBlogRequest{},
+	models.GetBlogByIDResponse{},
+	models.GetTagsResponse{},
+	models.GetCommentsResponse{},
+	models.GetModerationQueueResponse{},
+	models.GetReadingListResponse{},
+	models.GetSharedReadingListResponse{},
+	models.GetFeedResponse{},
+	models.GetAnalyticsResponse{},
+	models.GetTrendingResponse{},
+	models.GetRelatedResponse{},
+	models.SearchResponse{},
+	apperror.Problem{},
+}
+
+func TestResponseTypesHaveNoSensitiveFields(t *testing.T) {
+	for _, value := range responseTypes {
+		typ := reflect.TypeOf(value)
+		for _, path := range jsonPaths(typ, typ.Name(), map[reflect.Type]bool{}) {
+			if isSensitive(path[strings.LastIndex(path, ".")+1:]) {
+				t.Errorf("%s exposes sensitive field %s", typ, path)
+			}
+		}
+	}
+}
+
+func TestUserNeverMarshalsSecrets(t *testing.T) {
+	user := models.User{
+		Email:       "jane@example.com",
+		Password:    "$2a$10$abcdefghijklmnopqrstuv",
+		CreatedAt:   time.Now(),
+		UserId:      "6f1c2a9e-5d3b-4c8a-9f0e-1b2c3d4e5f60",
+		Name:        "Jane Doe",
+		Role:        models.RoleAdmin,
+		DisplayName: "Jane",
+	}
+	for name, value := range map[string]any{
+		"value":   user,
+		"pointer": &user,
+		"nested":  models.ResponseData{Data: []models.User{user}},
+	} {
+		body, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, secret := range []string{user.Password, user.Email, `"password"`, `"role"`} {
+			if strings.Contains(string(body), secret) {
+				t.Errorf("%s: marshalled user contains %s: %s", name, secret, body)
+			}
+		}
+	}
+}
+
+func TestAccountOnlyAddsOwnerFields(t *testing.T) {
+	user := models.User{Email: "jane@example.com", Password: "hash", UserId: "u1", Name: "Jane", Role: models.RoleUser}
+	body, err := json.Marshal(user.Account())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "hash") {
+		t.Errorf("account contains the password hash: %s", body)
+	}
+	if !strings.Contains(string(body), user.Email) {
+		t.Errorf("account is missing the email: %s", body)
+	}
+}
+
+// TestDocumentedResponsesHaveNoSensitiveFields walks every response schema
+// of the generated OpenAPI document, so new endpoints are covered too.
+func TestDocumentedResponsesHaveNoSensitiveFields(t *testing.T) {
+	raw, err := os.ReadFile("../docs/swagger.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths       map[string]map[string]map[string]any `json:"paths"`
+		Definitions map[string]any                       `json:"definitions"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatal(err)
+	}
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			responses, _ := operation["responses"].(map[string]any)
+			for status, response := range responses {
+				response, _ := response.(map[string]any)
+				where := strings.ToUpper(method) + " " + path + " " + status
+				walkSchema(t, where, response["schema"], doc.Definitions, map[string]bool{})
+			}
+		}
+	}
+}
+
+func walkSchema(t *testing.T, where string, schema any, definitions map[string]any, seen map[string]bool) {
+	node, ok := schema.(map[string]any)
+	if !ok {
+		return
+	}
+	if ref, ok := node["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		if !seen[name] {
+			seen[name] = true
+			walkSchema(t, where, definitions[name], definitions, seen)
+		}
+	}
+	properties, _ := node["properties"].(map[string]any)
+	for name, property := range properties {
+		if isSensitive(name) {
+			t.Errorf("%s exposes sensitive field %s", where, name)
+		}
+		walkSchema(t, where, property, definitions, seen)
+	}
+	walkSchema(t, where, node["items"], definitions, seen)
+	walkSchema(t, where, node["additionalProperties"], definitions, seen)
+	allOf, _ := node["allOf"].([]any)
+	for _, part := range allOf {
+		walkSchema(t, where, part, definitions, seen)
+	}
+}
+
+// jsonPaths lists the JSON names reachable from typ
+func jsonPaths(typ reflect.Type, prefix string, seen map[reflect.Type]bool) []string {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] || typ == reflect.TypeOf(time.Time{}) {
+		return nil
+	}
+	seen[typ] = true
+	var paths []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			paths = append(paths, jsonPaths(field.Type, prefix, seen)...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		paths = append(paths, prefix+"."+name)
+		paths = append(paths, jsonPaths(field.Type, prefix+"."+name, seen)...)
+	}
+	return paths
+}
@@ -1,37 +1,56 @@
 package models
 
-type CreateBlogRequest struct {
-	Title   string   `json:"title" example:"My Blog Title" validate:"required"`
-	Content string   `json:"content" example:"Blog content" validate:"required"`
-	Tags    []string `json:"tags" example:"golang,redis" validate:"required"`
-}
-
 type CreateBlogSuccess struct {
 	Message string            `json:"message" example:"Blog created successfully."`
-	Data    map[string]string `json:"data" example:"blog_id: 1234567890"`
-}
-
-type CreateBlogError struct {
-	Message string `json:"message" example:"Failed to create blog."`
-	Error   string `json:"error" example:"Failed to create blog."`
+	Data    map[string]string `json:"data" example:"blog_id: 01J9Z3T6Q8X4V2M7N5K0R1B3CD"`
 }
 
 type GetAllBlogRequest struct {
 	Message string            `json:"message" example:"Get all blogs successfully."`
-	Data    map[string]string `json:"data" example:"blogs:blog_data,page:1,limit:10,total_pages:1,total_item:1"`
+	Data    map[string]string `json:"data" example:"blogs:blog_data,page:1,limit:10,sort:newest,next_cursor:eyJzIjoibmV3ZXN0In0,prev_cursor:,total_pages:1,total_item:1"`
 }
 
 type GetBlogByIDResponse struct {
 	Message string `json:"message" example:"Get blog by id successfully."`
-	Data    Blog   `json:"data" example:{
-		"id": "1234567890",
-		"blog_id": "1234567890",
-		"title": "My Blog Title",
-		"content": "Blog content",
-		"slug": "my-blog-title",
-		"created_at": "2021-01-01T00:00:00Z",
-		"tags": ["golang", "redis"],
-		"author_id": "1234567890",
-		"updated_at": "2021-01-01T00:00:00Z"
-	}`
+	Data    Blog   `json:"data"`
+}
+
+type GetCommentsResponse struct {
+	Message string            `json:"message" example:"Get comments successfully."`
+	Data    map[string]string `json:"data" example:"comments:comment_threads,page:1,limit:10,total_pages:1,total_item:1"`
+}
+
+type GetModerationQueueResponse struct {
+	Message string            `json:"message" example:"Get moderation queue successfully."`
+	Data    map[string]string `json:"data" example:"comments:comment_data,status:pending,page:1,limit:10,total_pages:1,total_item:1"`
+}
+
+type GetReadingListResponse struct {
+	Message string            `json:"message" example:"Get reading list successfully."`
+	Data    map[string]string `json:"data" example:"list:reading_list,blogs:blog_summaries,hidden:0,page:1,limit:10,total_pages:1,total_item:1"`
+}
+
+type GetSharedReadingListResponse struct {
+	Message string            `json:"message" example:"Get reading list successfully."`
+	Data    map[string]string `json:"data" example:"list:shared_reading_list,blogs:blog_summaries,hidden:0,page:1,limit:10,total_pages:1,total_item:1"`
+}
+
+type GetFeedResponse struct {
+	Message string            `json:"message" example:"Get feed successfully."`
+	Data    map[string]string `json:"data" example:"blogs:blog_summaries,limit:10,next_cursor:eyJzIjoibmV3ZXN0In0"`
+}
+
+type GetAnalyticsResponse struct {
+	Message string    `json:"message" example:"Get analytics successfully."`
+	Data    Analytics `json:"data"`
+}
+
+type GetTrendingResponse struct {
+	Message string            `json:"message" example:"Get trending blogs successfully."`
+	Data    map[string]string `json:"data" example:"blogs:trending_blogs,window:24h,tag:golang,limit:10"`
+}
+
+type GetRelatedResponse struct {
+	Message string            `json:"message" example:"Get related blogs successfully."`
+	Data    map[string]string `json:"data" example:"blogs:related_blogs,limit:5"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Tag struct {
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name" example:"golang"`
+	Description string             `json:"description" bson:"description" example:"The Go programming language"`
+	Aliases     []string           `json:"aliases" bson:"aliases" example:"go"`
+	PostCount   int64              `json:"post_count" bson:"-" example:"12"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at" example:"2021-01-01T00:00:00Z"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at" example:"2021-01-01T00:00:00Z"`
+}
+
+type UpsertTagRequest struct {
+	Description string   `json:"description" example:"The Go programming language" validate:"max=500"`
+	Aliases     []string `json:"aliases" example:"go" validate:"max=20,dive,tag"`
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name" example:"golang" validate:"required,tag"`
+}
+
+type GetTagsResponse struct {
+	Message string `json:"message" example:"Get tags successfully."`
+	Data    []Tag  `json:"data"`
+}
@@ -0,0 +1,7 @@
+package models
+
+type TOCEntry struct {
+	Level  int    `json:"level" bson:"level" example:"2"`
+	Title  string `json:"title" bson:"title" example:"Getting started"`
+	Anchor string `json:"anchor" bson:"anchor" example:"getting-started"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// Trending windows
+const (
+	TrendingDay   = "24h"
+	TrendingWeek  = "7d"
+	TrendingMonth = "30d"
+)
+
+var TrendingWindows = []string{TrendingDay, TrendingWeek, TrendingMonth}
+
+// TrendingBlog is a blog summary with its decayed activity score. Blogs
+// filling in for missing activity score 0.
+type TrendingBlog struct {
+	BlogSummary
+	TrendingScore float64 `json:"trending_score" example:"42.5"`
+}
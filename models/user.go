@@ -1,16 +1,146 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RoleUser   = "user"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// What happens to a deleted account's blogs
+const (
+	BlogPolicyDelete    = "delete"
+	BlogPolicyAnonymize = "anonymize"
+	BlogPolicyTransfer  = "transfer"
+)
+
+// DeletedUserID is the author of blogs whose account was deleted
+const DeletedUserID = "deleted"
+
+// User is the stored user, including its password hash. It is never sent
+// to clients: respond with Profile, AuthorSummary or Account instead.
 type User struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
 	Email     string             `json:"email" bson:"email"`
-	Password  string             `json:"password" bson:"password"`
+	Password  string             `json:"-" bson:"password"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UserId    string             `json:"user_id" bson:"user_id"`
 	Name      string             `json:"name" bson:"name"`
+	Role      string             `json:"role" bson:"role"`
+	// Public profile
+	DisplayName string            `json:"display_name" bson:"display_name"`
+	Bio         string            `json:"bio" bson:"bio"`
+	AvatarURL   string            `json:"avatar_url" bson:"avatar_url"`
+	SocialLinks map[string]string `json:"social_links" bson:"social_links"`
+	// Account security
+	EmailVerified            bool      `json:"-" bson:"email_verified"`
+	PendingEmail             string    `json:"-" bson:"pending_email,omitempty"`
+	EmailVerificationHash    string    `json:"-" bson:"email_verification_hash,omitempty"`
+	EmailVerificationExpires time.Time `json:"-" bson:"email_verification_expires,omitempty"`
+	// Tokens issued before this are revoked
+	TokensValidAfter time.Time `json:"-" bson:"tokens_valid_after,omitempty"`
+	// Comments of shadow-banned users are filed as spam without telling them
+	ShadowBanned bool `json:"-" bson:"shadow_banned,omitempty"`
+	// Set once the account is being deleted, it is gone for everyone else
+	Deletion *AccountDeletion `json:"-" bson:"deletion,omitempty"`
+}
+
+// AccountDeletion is what the owner of a deleted account chose for its
+// blogs, kept until its data is gone so an interrupted deletion resumes
+// the same way
+type AccountDeletion struct {
+	Policy      string    `bson:"policy"`
+	TransferTo  string    `bson:"transfer_to,omitempty"`
+	RequestedAt time.Time `bson:"requested_at"`
+}
+
+// MarshalJSON only ever writes the public profile, so a User returned by
+// mistake leaks neither its hash nor its email.
+func (u User) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.Profile())
+}
+
+// UserProfile is what anyone can see of a user
+type UserProfile struct {
+	UserID      string            `json:"user_id" example:"6f1c2a9e-5d3b-4c8a-9f0e-1b2c3d4e5f60"`
+	DisplayName string            `json:"display_name" example:"Jane Doe"`
+	Bio         string            `json:"bio" example:"Writes about Go and Redis."`
+	AvatarURL   string            `json:"avatar_url" example:"https://example.com/jane.png"`
+	SocialLinks map[string]string `json:"social_links"`
+	CreatedAt   time.Time         `json:"created_at" example:"2021-01-01T00:00:00Z"`
+}
+
+// AuthorSummary is the author embedded in blog responses
+type AuthorSummary struct {
+	UserID      string `json:"user_id" example:"6f1c2a9e-5d3b-4c8a-9f0e-1b2c3d4e5f60"`
+	DisplayName string `json:"display_name" example:"Jane Doe"`
+	AvatarURL   string `json:"avatar_url" example:"https://example.com/jane.png"`
+}
+
+// Account is the logged-in user's view of themselves
+type Account struct {
+	UserProfile
+	Name          string `json:"name" example:"Jane Doe"`
+	Email         string `json:"email" example:"jane@example.com"`
+	EmailVerified bool   `json:"email_verified" example:"true"`
+	PendingEmail  string `json:"pending_email,omitempty" example:"jane@example.org"`
+	Role          string `json:"role" example:"user"`
+}
+
+// displayName falls back to the account name for users without a profile
+func (u *User) displayName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Name
+}
+
+func (u *User) Profile() UserProfile {
+	socialLinks := u.SocialLinks
+	if socialLinks == nil {
+		socialLinks = map[string]string{}
+	}
+	return UserProfile{
+		UserID:      u.UserId,
+		DisplayName: u.displayName(),
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		SocialLinks: socialLinks,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+func (u *User) AuthorSummary() AuthorSummary {
+	return AuthorSummary{
+		UserID:      u.UserId,
+		DisplayName: u.displayName(),
+		AvatarURL:   u.AvatarURL,
+	}
+}
+
+func (u *User) Account() Account {
+	return Account{
+		UserProfile:   u.Profile(),
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		PendingEmail:  u.PendingEmail,
+		Role:          u.Role,
+	}
+}
+
+// ProfileFields are the parts of the profile a user can edit
+func (u *User) ProfileFields() UpdateProfileRequest {
+	return UpdateProfileRequest{
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		SocialLinks: u.SocialLinks,
+	}
 }
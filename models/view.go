@@ -0,0 +1,35 @@
+package models
+
+// DirectReferrer stands for views without a usable Referer header
+const DirectReferrer = "direct"
+
+// Analytics are the views of an author's blogs over a range of days (UTC)
+type Analytics struct {
+	From          string          `json:"from" example:"2021-01-01"`
+	To            string          `json:"to" example:"2021-01-30"`
+	Views         int64           `json:"views" example:"1234"`
+	ViewsOverTime []DailyViews    `json:"views_over_time"`
+	Referrers     []ReferrerViews `json:"referrers"`
+	TopPosts      []PostViews     `json:"top_posts"`
+}
+
+// DailyViews are the views of one day. Uniques count each visitor once
+// per blog, so a visitor reading two blogs counts twice.
+type DailyViews struct {
+	Date    string `json:"date" bson:"date" example:"2021-01-01"`
+	Views   int64  `json:"views" bson:"views" example:"42"`
+	Uniques int64  `json:"uniques" bson:"uniques" example:"30"`
+}
+
+// ReferrerViews are the views coming from one referring host
+type ReferrerViews struct {
+	Referrer string `json:"referrer" bson:"referrer" example:"news.ycombinator.com"`
+	Views    int64  `json:"views" bson:"views" example:"12"`
+}
+
+// PostViews are the views of one blog
+type PostViews struct {
+	BlogID string `json:"blog_id" bson:"blog_id" example:"b6f8a7c2d1e04b5f9a3c2d1e0f4b5a6c"`
+	Title  string `json:"title" bson:"-" example:"My first blog"`
+	Views  int64  `json:"views" bson:"views" example:"120"`
+}
@@ -0,0 +1,98 @@
+// Package related finds blogs similar to a given one. Blogs are compared
+// on their tags and title words, each weighted by how rare it is across
+// the published blogs (TF-IDF) so shared niche tags count more than
+// shared popular ones.
+package related
+
+import (
+	"math"
+	"sort"
+
+	"inkinkink111/go-blog-management/search"
+)
+
+// Tags say more about a blog than single title words
+const (
+	tagWeight   = 2.0
+	titleWeight = 1.0
+)
+
+// Document is what blogs are compared on
+type Document struct {
+	ID    string
+	Title string
+	Tags  []string
+}
+
+// Match is a related blog and its cosine similarity, between 0 and 1
+type Match struct {
+	BlogID string  `json:"blog_id"`
+	Score  float64 `json:"score"`
+}
+
+// Rank returns the documents of corpus similar to doc, most similar
+// first, leaving doc itself out. limit caps the matches unless it is 0.
+func Rank(doc Document, corpus []Document, limit int) []Match {
+	vectors := make([]map[string]float64, len(corpus))
+	frequencies := map[string]int{}
+	for i := range corpus {
+		vectors[i] = terms(corpus[i])
+		for term := range vectors[i] {
+			frequencies[term]++
+		}
+	}
+	weigh := func(vector map[string]float64) float64 {
+		var norm float64
+		for term, weight := range vector {
+			frequency := frequencies[term]
+			if frequency == 0 {
+				delete(vector, term)
+				continue
+			}
+			vector[term] = weight * math.Log(1+float64(len(corpus))/float64(frequency))
+			norm += vector[term] * vector[term]
+		}
+		return math.Sqrt(norm)
+	}
+	target := terms(doc)
+	targetNorm := weigh(target)
+	matches := []Match{}
+	if targetNorm == 0 {
+		return matches
+	}
+	for i := range corpus {
+		if corpus[i].ID == doc.ID {
+			continue
+		}
+		norm := weigh(vectors[i])
+		var dot float64
+		for term, weight := range vectors[i] {
+			dot += weight * target[term]
+		}
+		if dot > 0 {
+			matches = append(matches, Match{BlogID: corpus[i].ID, Score: dot / (norm * targetNorm)})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].BlogID < matches[j].BlogID
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// terms are a document's tags and title words with their weights
+func terms(doc Document) map[string]float64 {
+	vector := map[string]float64{}
+	for _, word := range search.Tokenize(doc.Title) {
+		vector["title:"+word] = titleWeight
+	}
+	for _, tag := range doc.Tags {
+		vector["tag:"+tag] = tagWeight
+	}
+	return vector
+}
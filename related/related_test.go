@@ -0,0 +1,110 @@
+package related_test
+
+import (
+	"testing"
+
+	"inkinkink111/go-blog-management/related"
+)
+
+var corpus = []related.Document{
+	{ID: "fiber-intro", Title: "Getting started with Fiber", Tags: []string{"go", "fiber", "web"}},
+	{ID: "fiber-middleware", Title: "Writing Fiber middleware", Tags: []string{"go", "fiber", "web"}},
+	{ID: "fiber-only", Title: "Benchmarks", Tags: []string{"fiber"}},
+	{ID: "go-only", Title: "Generics", Tags: []string{"go"}},
+	{ID: "go-errors", Title: "Errors", Tags: []string{"go"}},
+	{ID: "go-modules", Title: "Modules", Tags: []string{"go"}},
+	{ID: "cooking", Title: "Sourdough bread", Tags: []string{"baking"}},
+}
+
+func ids(matches []related.Match) []string {
+	out := make([]string, len(matches))
+	for i, match := range matches {
+		out[i] = match.BlogID
+	}
+	return out
+}
+
+func TestRank(t *testing.T) {
+	matches := related.Rank(corpus[0], corpus, 0)
+	if len(matches) == 0 || matches[0].BlogID != "fiber-middleware" {
+		t.Fatalf("got %v, want fiber-middleware first", ids(matches))
+	}
+	for i, match := range matches {
+		if match.BlogID == "fiber-intro" {
+			t.Errorf("the blog itself is in its matches")
+		}
+		if match.BlogID == "cooking" {
+			t.Errorf("a blog sharing nothing is in the matches")
+		}
+		if match.Score <= 0 || match.Score > 1+1e-9 {
+			t.Errorf("%s has score %v, want it in (0, 1]", match.BlogID, match.Score)
+		}
+		if i > 0 && match.Score > matches[i-1].Score {
+			t.Errorf("%s ranks after %s with a higher score", match.BlogID, matches[i-1].BlogID)
+		}
+	}
+	if len(matches) != 5 {
+		t.Errorf("got %v, want the 5 blogs sharing a tag or title word", ids(matches))
+	}
+}
+
+func TestRankFavoursRareTags(t *testing.T) {
+	// "fiber" is on 3 blogs and "go" on 5, so sharing only "fiber" says more
+	scores := map[string]float64{}
+	for _, match := range related.Rank(corpus[0], corpus, 0) {
+		scores[match.BlogID] = match.Score
+	}
+	if scores["fiber-only"] <= scores["go-only"] {
+		t.Errorf("fiber-only scores %v, go-only %v, want the rarer tag higher", scores["fiber-only"], scores["go-only"])
+	}
+}
+
+func TestRankBreaksTiesByID(t *testing.T) {
+	matches := related.Rank(related.Document{ID: "new", Tags: []string{"go"}}, corpus, 0)
+	want := []string{"go-errors", "go-modules", "go-only", "fiber-intro", "fiber-middleware"}
+	got := ids(matches)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if matches[0].Score != matches[1].Score || matches[1].Score != matches[2].Score {
+		t.Errorf("go-errors, go-modules and go-only score %v, %v and %v, want a tie", matches[0].Score, matches[1].Score, matches[2].Score)
+	}
+}
+
+func TestRankLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, 5},
+		{1, 1},
+		{3, 3},
+		{10, 5},
+	}
+	for _, tt := range tests {
+		if got := related.Rank(corpus[0], corpus, tt.limit); len(got) != tt.want {
+			t.Errorf("limit %d gave %d matches, want %d", tt.limit, len(got), tt.want)
+		}
+	}
+}
+
+func TestRankWithoutSharedTerms(t *testing.T) {
+	docs := []related.Document{
+		{ID: "empty"},
+		{ID: "stop-words", Title: "The and of"},
+		{ID: "unknown", Title: "Quantum", Tags: []string{"physics"}},
+	}
+	for _, doc := range docs {
+		if matches := related.Rank(doc, corpus, 0); matches == nil || len(matches) != 0 {
+			t.Errorf("%s: got %v, want no matches", doc.ID, matches)
+		}
+	}
+	if matches := related.Rank(corpus[0], nil, 0); len(matches) != 0 {
+		t.Errorf("an empty corpus gave %v, want no matches", ids(matches))
+	}
+}
@@ -2,14 +2,46 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"inkinkink111/go-blog-management/db"
 	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/utils"
+	"log"
+	"slices"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var ErrDuplicateBlogID = errors.New("blog id already exists")
+
+// MongoDB error code of dropping an index that doesn't exist
+const indexNotFoundCode = 27
+
+// ErrVersionConflict means the blog changed since the caller read it
+var ErrVersionConflict = errors.New("blog was modified concurrently")
+
+// Case-insensitive matching so tag renames and merges catch every spelling
+// of a tag
+var tagCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// List queries and their indexes sort titles regardless of case first, but
+// still tell apart legacy blog ids that only differ by case, so the blog_id
+// tie-break gives every blog its own place in a page. Tags are normalized
+// on write and by Migrate, so they match exactly.
+var listCollation = &options.Collation{Locale: "en", Strength: 3}
+
+// Names of the list indexes before they moved to listCollation
+var tagCollationListIndexes = []string{
+	"author_id_1_created_at_-1",
+	"created_at_-1_blog_id_-1",
+	"updated_at_-1_blog_id_-1",
+	"title_1_blog_id_1",
+	"popularity_-1_blog_id_-1",
+	"like_count_-1_blog_id_-1",
+}
+
 type BlogRepository struct {
 	collection *mongo.Collection
 }
@@ -20,69 +52,632 @@ func NewBlogRepository() *BlogRepository {
 	}
 }
 
-func (br *BlogRepository) GetAllBlogs(page, limit int, tags []string) ([]models.Blog, int64, error) {
+type blogSort struct {
+	field string
+	order int
+}
+
+var blogSorts = map[string]blogSort{
+	models.SortNewest:     {"created_at", -1},
+	models.SortOldest:     {"created_at", 1},
+	models.SortUpdated:    {"updated_at", -1},
+	models.SortTitle:      {"title", 1},
+	models.SortPopularity: {"popularity", -1},
+	models.SortLikes:      {"like_count", -1},
+}
+
+// GetAllBlogs returns a page of blogs matching the tag filters, either at
+// an offset or next to a cursor, and whether more blogs follow in that
+// direction. Unless WithContent is set the heavy content fields are left
+// out so callers can build summaries.
+func (br *BlogRepository) GetAllBlogs(query models.BlogListQuery) ([]models.Blog, int64, bool, error) {
 	var blogs []models.Blog
+	// Filter, drafts are never listed
+	filter := bson.M{"status": bson.M{"$ne": models.BlogDraft}}
+	tagFilter := bson.M{}
+	if len(query.Tags) > 0 {
+		if query.TagMode == models.TagModeAll {
+			tagFilter["$all"] = query.Tags
+		} else {
+			tagFilter["$in"] = query.Tags
+		}
+	}
+	if len(query.ExcludedTags) > 0 {
+		tagFilter["$nin"] = query.ExcludedTags
+	}
+	if len(tagFilter) > 0 {
+		filter["tags"] = tagFilter
+	}
+	if query.AuthorID != "" {
+		filter["author_id"] = query.AuthorID
+	}
 	// Get total blogs count
-	totalCount, err := br.collection.CountDocuments(context.TODO(), bson.M{})
+	totalCount, err := br.collection.CountDocuments(context.TODO(), filter, options.Count().SetCollation(listCollation))
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, false, err
 	}
-	// Pagination
-	skip := (page - 1) * limit
-	options := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit))
-	// Filter
-	filter := bson.M{}
-	if len(tags) > 0 {
-		filter["tags"] = bson.M{"$in": tags}
+	// Sort with blog_id as tie-breaker so pages are stable
+	sort, ok := blogSorts[query.Sort]
+	if !ok {
+		sort = blogSorts[models.SortNewest]
+	}
+	order := sort.order
+	options := options.Find().SetCollation(listCollation).SetLimit(int64(query.Limit + 1))
+	if query.Cursor != nil {
+		if query.Cursor.Before {
+			order = -order
+		}
+		filter = bson.M{"$and": bson.A{filter, cursorFilter(query.Cursor, sort.field, order)}}
+	} else {
+		options.SetSkip(int64((query.Page - 1) * query.Limit))
+	}
+	options.SetSort(bson.D{{Key: sort.field, Value: order}, {Key: "blog_id", Value: order}})
+	if !query.WithContent {
+		options.SetProjection(bson.M{"content": 0, "content_html": 0, "toc": 0})
 	}
 	cursor, err := br.collection.Find(context.TODO(), filter, options)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, false, err
 	}
 	defer cursor.Close(context.TODO())
 	for cursor.Next(context.TODO()) {
 		var blog models.Blog
 		err := cursor.Decode(&blog)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, false, err
 		}
 		blogs = append(blogs, blog)
 	}
-	return blogs, totalCount, nil
+	// One extra blog was fetched to know if there is more
+	hasMore := len(blogs) > query.Limit
+	if hasMore {
+		blogs = blogs[:query.Limit]
+	}
+	if query.Cursor != nil && query.Cursor.Before {
+		slices.Reverse(blogs)
+	}
+	return blogs, totalCount, hasMore, nil
+}
+
+// cursorFilter matches blogs strictly past the cursor in sort order
+func cursorFilter(cursor *models.ListCursor, field string, order int) bson.M {
+	var value any
+	switch cursor.Sort {
+	case models.SortTitle:
+		value = cursor.Title
+	case models.SortPopularity:
+		value = cursor.Popularity
+	case models.SortLikes:
+		value = cursor.Likes
+	default:
+		value = cursor.Time
+	}
+	op := "$gt"
+	if order < 0 {
+		op = "$lt"
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: value}},
+		bson.M{field: value, "blog_id": bson.M{op: cursor.BlogID}},
+	}}
+}
+
+// GetFeedBlogs returns, newest first, published blogs by any of authorIDs
+// or tagged with any of tags that come after cursor, and whether more
+// follow. Content fields are left out.
+func (br *BlogRepository) GetFeedBlogs(authorIDs, tags []string, cursor *models.ListCursor, limit int) ([]models.Blog, bool, error) {
+	sources := bson.A{}
+	if len(authorIDs) > 0 {
+		sources = append(sources, bson.M{"author_id": bson.M{"$in": authorIDs}})
+	}
+	if len(tags) > 0 {
+		sources = append(sources, bson.M{"tags": bson.M{"$in": tags}})
+	}
+	if len(sources) == 0 {
+		return []models.Blog{}, false, nil
+	}
+	filter := bson.M{"status": bson.M{"$ne": models.BlogDraft}, "$or": sources}
+	if cursor != nil {
+		filter = bson.M{"$and": bson.A{filter, cursorFilter(cursor, "created_at", -1)}}
+	}
+	opts := options.Find().
+		SetCollation(listCollation).
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "blog_id", Value: -1}}).
+		SetLimit(int64(limit + 1)).
+		SetProjection(bson.M{"content": 0, "content_html": 0, "toc": 0})
+	result, err := br.collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	blogs := []models.Blog{}
+	if err := result.All(context.TODO(), &blogs); err != nil {
+		return nil, false, err
+	}
+	// One extra blog was fetched to know if there is more
+	hasMore := len(blogs) > limit
+	if hasMore {
+		blogs = blogs[:limit]
+	}
+	return blogs, hasMore, nil
+}
+
+// BackfillDefaults sets fields added after launch on older blogs so they
+// sort and page like new ones.
+func (br *BlogRepository) BackfillDefaults() error {
+	defaults := bson.M{"popularity": 0, "version": 1, "comment_count": 0, "like_count": 0}
+	for field, value := range defaults {
+		_, err := br.collection.UpdateMany(context.TODO(),
+			bson.M{field: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{field: value}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DedupeBlogIDs gives a new id to every blog sharing its blog_id with an
+// older one. The legacy 8 character ids were not random enough to rule
+// out collisions, and a duplicate keeps the unique index from being
+// built. Comments, reactions and saves can't tell the blogs apart, they
+// stay with the oldest.
+func (br *BlogRepository) DedupeBlogIDs() error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$blog_id", "ids": bson.M{"$push": "$_id"}}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	}
+	cursor, err := br.collection.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		BlogID string `bson:"_id"`
+		IDs    []any  `bson:"ids"`
+	}
+	if err := cursor.All(context.TODO(), &duplicates); err != nil {
+		return err
+	}
+	for _, duplicate := range duplicates {
+		for _, id := range duplicate.IDs[1:] {
+			blogID := utils.GenerateID()
+			update := bson.M{"$set": bson.M{"blog_id": blogID}, "$inc": bson.M{"version": 1}}
+			if _, err := br.collection.UpdateOne(context.TODO(), bson.M{"_id": id}, update); err != nil {
+				return err
+			}
+			log.Printf("Blog id %s was used twice, a newer blog now has id %s", duplicate.BlogID, blogID)
+		}
+	}
+	return nil
+}
+
+// NormalizeLegacyTags rewrites the tags of blogs stored before tags were
+// normalized, which list queries would no longer match.
+func (br *BlogRepository) NormalizeLegacyTags() error {
+	filter := bson.M{"tags": bson.M{"$regex": `[\p{Lu}\s]|^[-#]`}}
+	opts := options.Find().SetProjection(bson.M{"blog_id": 1, "tags": 1})
+	cursor, err := br.collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return err
+	}
+	var blogs []models.Blog
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return err
+	}
+	for _, blog := range blogs {
+		tags := utils.NormalizeTags(blog.Tags)
+		if slices.Equal(tags, blog.Tags) {
+			continue
+		}
+		update := bson.M{"$set": bson.M{"tags": tags}, "$inc": bson.M{"version": 1}}
+		if _, err := br.collection.UpdateOne(context.TODO(), bson.M{"blog_id": blog.BlogID}, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillRenderedContent renders the Markdown of blogs stored before
+// rendering, and stores their HTML, table of contents and summary.
+// Derived fields don't change the version or the update time.
+func (br *BlogRepository) BackfillRenderedContent() error {
+	filter := bson.M{
+		"content":      bson.M{"$nin": bson.A{"", nil}},
+		"content_html": bson.M{"$in": bson.A{"", nil}},
+	}
+	opts := options.Find().SetProjection(bson.M{"blog_id": 1, "content": 1})
+	cursor, err := br.collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+	for cursor.Next(context.TODO()) {
+		var blog models.Blog
+		if err := cursor.Decode(&blog); err != nil {
+			return err
+		}
+		contentHTML, toc, err := utils.RenderMarkdown(blog.Content)
+		if err != nil {
+			return err
+		}
+		excerpt, wordCount, readingTime := utils.SummarizeContent(contentHTML)
+		_, err = br.collection.UpdateOne(context.TODO(), bson.M{"blog_id": blog.BlogID}, bson.M{"$set": bson.M{
+			"content_html": contentHTML,
+			"toc":          toc,
+			"excerpt":      excerpt,
+			"word_count":   wordCount,
+			"reading_time": readingTime,
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
 }
 
 func (br *BlogRepository) GetBlogByID(blogID string) (*models.Blog, error) {
 	var blog models.Blog
 	err := br.collection.FindOne(context.TODO(), bson.M{"blog_id": blogID}).Decode(&blog)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
 		return nil, err
 	}
-	if err == mongo.ErrNoDocuments {
+	return &blog, nil
+}
+
+// ScoredBlog is a blog with its text search relevance
+type ScoredBlog struct {
+	models.Blog `bson:",inline"`
+	Score       float64 `bson:"score"`
+}
+
+func (br *BlogRepository) EnsureIndexes() error {
+	for _, name := range tagCollationListIndexes {
+		_, err := br.collection.Indexes().DropOne(context.TODO(), name)
+		var commandErr mongo.CommandError
+		if err != nil && !(errors.As(err, &commandErr) && commandErr.Code == indexNotFoundCode) {
+			return err
+		}
+	}
+	_, err := br.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "blog_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Tag renames and merges
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}},
+			Options: options.Index().SetCollation(tagCollation),
+		},
+		// Tag filters, author pages and list sorts, with the collation list
+		// queries run under
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}},
+			Options: options.Index().SetName("tags_1_list").SetCollation(listCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "author_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("author_id_1_created_at_-1_list").SetCollation(listCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "blog_id", Value: -1}},
+			Options: options.Index().SetName("created_at_-1_blog_id_-1_list").SetCollation(listCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "updated_at", Value: -1}, {Key: "blog_id", Value: -1}},
+			Options: options.Index().SetName("updated_at_-1_blog_id_-1_list").SetCollation(listCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "title", Value: 1}, {Key: "blog_id", Value: 1}},
+			Options: options.Index().SetName("title_1_blog_id_1_list").SetCollation(listCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "popularity", Value: -1}, {Key: "blog_id", Value: -1}},
+			Options: options.Index().SetName("popularity_-1_blog_id_-1_list").SetCollation(listCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "like_count", Value: -1}, {Key: "blog_id", Value: -1}},
+			Options: options.Index().SetName("like_count_-1_blog_id_-1_list").SetCollation(listCollation),
+		},
+		{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "tags", Value: "text"}, {Key: "content", Value: "text"}},
+			Options: options.Index().SetName("blog_text").SetWeights(bson.D{
+				{Key: "title", Value: 10},
+				{Key: "tags", Value: 5},
+				{Key: "content", Value: 1},
+			}),
+		},
+	})
+	return err
+}
+
+// SearchBlogs runs a MongoDB $text search, which understands "phrases"
+// and -negations, and returns blogs ranked by relevance.
+func (br *BlogRepository) SearchBlogs(query string, page, limit int) ([]ScoredBlog, int64, error) {
+	var blogs []ScoredBlog
+	filter := bson.M{"$text": bson.M{"$search": query}, "status": bson.M{"$ne": models.BlogDraft}}
+	totalCount, err := br.collection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	skip := (page - 1) * limit
+	score := bson.M{"$meta": "textScore"}
+	options := options.Find().
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)).
+		SetProjection(bson.M{"score": score, "toc": 0}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "created_at", Value: -1}})
+	cursor, err := br.collection.Find(context.TODO(), filter, options)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return nil, 0, err
+	}
+	return blogs, totalCount, nil
+}
+
+// GetBlogsByIDs returns the blogs found for the given ids, in no
+// particular order.
+func (br *BlogRepository) GetBlogsByIDs(blogIDs []string) ([]models.Blog, error) {
+	var blogs []models.Blog
+	cursor, err := br.collection.Find(context.TODO(), bson.M{"blog_id": bson.M{"$in": blogIDs}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return nil, err
+	}
+	return blogs, nil
+}
+
+// GetBlogSummariesByIDs is GetBlogsByIDs without the heavy content fields
+func (br *BlogRepository) GetBlogSummariesByIDs(blogIDs []string) ([]models.Blog, error) {
+	var blogs []models.Blog
+	opts := options.Find().SetProjection(bson.M{"content": 0, "content_html": 0, "toc": 0})
+	cursor, err := br.collection.Find(context.TODO(), bson.M{"blog_id": bson.M{"$in": blogIDs}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return nil, err
+	}
+	return blogs, nil
+}
+
+// CountTags returns the number of published blogs using each tag.
+func (br *BlogRepository) CountTags() (map[string]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$ne": models.BlogDraft}}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := br.collection.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Tag   string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(context.TODO(), &rows); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Tag] += row.Count
+	}
+	return counts, nil
+}
+
+// HasTag reports whether any blog, draft or not, uses tag
+func (br *BlogRepository) HasTag(tag string) (bool, error) {
+	count, err := br.collection.CountDocuments(context.TODO(), bson.M{"tags": tag}, options.Count().SetCollation(tagCollation).SetLimit(1))
+	return count > 0, err
+}
+
+// ReplaceTag swaps tag from for tag to on every blog using it, in any
+// case, and returns the ids of the rewritten blogs. Each blog is
+// rewritten in a single update so it never holds both tags.
+func (br *BlogRepository) ReplaceTag(from, to string) ([]string, error) {
+	filter := bson.M{"tags": from}
+	opts := options.Find().SetProjection(bson.M{"blog_id": 1}).SetCollation(tagCollation)
+	cursor, err := br.collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var blogs []models.Blog
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return nil, err
+	}
+	if len(blogs) == 0 {
 		return nil, nil
 	}
-	return &blog, nil
+	// Both tags become to, in place, then duplicates are dropped
+	renamed := bson.M{"$map": bson.M{
+		"input": "$tags",
+		"as":    "tag",
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{bson.M{"$toLower": "$$tag"}, bson.A{from, to}}},
+			to,
+			"$$tag",
+		}},
+	}}
+	deduplicated := bson.M{"$reduce": bson.M{
+		"input":        renamed,
+		"initialValue": bson.A{},
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{"$$this", "$$value"}},
+			"$$value",
+			bson.M{"$concatArrays": bson.A{"$$value", bson.A{"$$this"}}},
+		}},
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"tags":    deduplicated,
+		"version": bson.M{"$add": bson.A{"$version", 1}},
+	}}}}
+	if _, err := br.collection.UpdateMany(context.TODO(), filter, update, options.Update().SetCollation(tagCollation)); err != nil {
+		return nil, err
+	}
+	blogIDs := make([]string, len(blogs))
+	for i, blog := range blogs {
+		blogIDs[i] = blog.BlogID
+	}
+	return blogIDs, nil
+}
+
+// SetReactionCounts stores the reaction counts kept in Redis, without
+// bumping the version
+func (br *BlogRepository) SetReactionCounts(blogID string, counts map[string]int64) error {
+	_, err := br.collection.UpdateOne(context.TODO(), bson.M{"blog_id": blogID}, bson.M{"$set": bson.M{
+		"reactions":  counts,
+		"like_count": counts[models.ReactionLike],
+	}})
+	return err
+}
+
+// IncCommentCount adjusts the comment count without bumping the version,
+// comments are not edits of the blog
+// IncPopularity adds flushed views to a blog's popularity
+func (br *BlogRepository) IncPopularity(blogID string, views int64) error {
+	_, err := br.collection.UpdateOne(context.TODO(), bson.M{"blog_id": blogID}, bson.M{"$inc": bson.M{"popularity": views}})
+	return err
+}
+
+func (br *BlogRepository) IncCommentCount(blogID string, delta int) error {
+	_, err := br.collection.UpdateOne(context.TODO(), bson.M{"blog_id": blogID}, bson.M{"$inc": bson.M{"comment_count": delta}})
+	return err
+}
+
+// ListPublishedTitles returns every published blog with only its id,
+// title and tags
+func (br *BlogRepository) ListPublishedTitles() ([]models.Blog, error) {
+	opts := options.Find().SetProjection(bson.M{"blog_id": 1, "title": 1, "tags": 1})
+	cursor, err := br.collection.Find(context.TODO(), bson.M{"status": bson.M{"$ne": models.BlogDraft}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	blogs := []models.Blog{}
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return nil, err
+	}
+	return blogs, nil
+}
+
+// ListSitemapBlogs returns the id, slug, tags, author and last update of
+// every published blog
+func (br *BlogRepository) ListSitemapBlogs() ([]models.Blog, error) {
+	opts := options.Find().SetProjection(bson.M{"blog_id": 1, "slug": 1, "tags": 1, "author_id": 1, "updated_at": 1})
+	cursor, err := br.collection.Find(context.TODO(), bson.M{"status": bson.M{"$ne": models.BlogDraft}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	blogs := []models.Blog{}
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return nil, err
+	}
+	return blogs, nil
+}
+
+// ListBlogIDsByAuthor returns the ids of every blog of an author, drafts
+// included
+func (br *BlogRepository) ListBlogIDsByAuthor(authorID string) ([]string, error) {
+	return br.listBlogIDs(bson.M{"author_id": authorID})
+}
+
+// ReassignBlogs gives every blog of one author to another and returns
+// their ids
+func (br *BlogRepository) ReassignBlogs(fromAuthorID, toAuthorID string) ([]string, error) {
+	blogIDs, err := br.listBlogIDs(bson.M{"author_id": fromAuthorID})
+	if err != nil || len(blogIDs) == 0 {
+		return nil, err
+	}
+	update := bson.M{"$set": bson.M{"author_id": toAuthorID}, "$inc": bson.M{"version": 1}}
+	if _, err := br.collection.UpdateMany(context.TODO(), bson.M{"blog_id": bson.M{"$in": blogIDs}}, update); err != nil {
+		return nil, err
+	}
+	return blogIDs, nil
+}
+
+// DeleteBlogsByAuthor deletes every blog of an author and returns their ids
+func (br *BlogRepository) DeleteBlogsByAuthor(authorID string) ([]string, error) {
+	blogIDs, err := br.listBlogIDs(bson.M{"author_id": authorID})
+	if err != nil || len(blogIDs) == 0 {
+		return nil, err
+	}
+	if _, err := br.collection.DeleteMany(context.TODO(), bson.M{"blog_id": bson.M{"$in": blogIDs}}); err != nil {
+		return nil, err
+	}
+	return blogIDs, nil
+}
+
+func (br *BlogRepository) listBlogIDs(filter bson.M) ([]string, error) {
+	cursor, err := br.collection.Find(context.TODO(), filter, options.Find().SetProjection(bson.M{"blog_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var blogs []models.Blog
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return nil, err
+	}
+	blogIDs := make([]string, len(blogs))
+	for i, blog := range blogs {
+		blogIDs[i] = blog.BlogID
+	}
+	return blogIDs, nil
+}
+
+func (br *BlogRepository) ListAllBlogs() ([]models.Blog, error) {
+	var blogs []models.Blog
+	cursor, err := br.collection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &blogs); err != nil {
+		return nil, err
+	}
+	return blogs, nil
 }
 
 func (br *BlogRepository) InsertBlog(blog *models.Blog) error {
 	_, err := br.collection.InsertOne(context.TODO(), blog)
 	if err != nil {
+		// Caller should retry with a fresh id
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicateBlogID
+		}
 		return err
 	}
 	return nil
 }
 
-func (br *BlogRepository) UpdateBlog(blog *models.Blog) error {
-	_, err := br.collection.UpdateOne(context.TODO(), bson.M{"blog_id": blog.BlogID}, bson.M{"$set": blog})
+// UpdateBlogFields sets only the given fields of a blog and bumps its
+// version, provided it is still at the expected version.
+func (br *BlogRepository) UpdateBlogFields(blogID string, version int64, fields bson.M) error {
+	result, err := br.collection.UpdateOne(context.TODO(),
+		bson.M{"blog_id": blogID, "version": version},
+		bson.M{"$set": fields, "$inc": bson.M{"version": 1}},
+	)
 	if err != nil {
 		return err
 	}
+	if result.MatchedCount == 0 {
+		return ErrVersionConflict
+	}
 	return nil
 }
 
-func (br *BlogRepository) DeleteBlog(blogID string) error {
-	_, err := br.collection.DeleteOne(context.TODO(), bson.M{"blog_id": blogID})
+// DeleteBlog deletes a blog, provided it is still at the expected version.
+func (br *BlogRepository) DeleteBlog(blogID string, version int64) error {
+	result, err := br.collection.DeleteOne(context.TODO(), bson.M{"blog_id": blogID, "version": version})
 	if err != nil {
 		return err
 	}
+	if result.DeletedCount == 0 {
+		return ErrVersionConflict
+	}
 	return nil
 }
@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"context"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CommentRepository struct {
+	collection *mongo.Collection
+}
+
+func NewCommentRepository() *CommentRepository {
+	return &CommentRepository{
+		collection: db.DB.Collection("comments"),
+	}
+}
+
+func (cr *CommentRepository) EnsureIndexes() error {
+	_, err := cr.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "comment_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Thread pages, then the replies of a page of threads
+		{Keys: bson.D{{Key: "blog_id", Value: 1}, {Key: "depth", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "thread_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "author_id", Value: 1}}},
+		// Moderation queue
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+	})
+	return err
+}
+
+func (cr *CommentRepository) InsertComment(comment *models.Comment) error {
+	_, err := cr.collection.InsertOne(context.TODO(), comment)
+	return err
+}
+
+func (cr *CommentRepository) GetComment(commentID string) (*models.Comment, error) {
+	var comment models.Comment
+	err := cr.collection.FindOne(context.TODO(), bson.M{"comment_id": commentID}).Decode(&comment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (cr *CommentRepository) UpdateCommentFields(commentID string, fields bson.M) error {
+	_, err := cr.collection.UpdateOne(context.TODO(), bson.M{"comment_id": commentID}, bson.M{"$set": fields})
+	return err
+}
+
+// statusFilter matches comments in status, counting comments stored
+// before moderation as approved
+func statusFilter(status string) any {
+	if status == models.CommentApproved {
+		return bson.M{"$in": bson.A{models.CommentApproved, nil}}
+	}
+	return status
+}
+
+// ListThreads returns a page of a blog's approved top-level comments,
+// oldest first, and the number of threads.
+func (cr *CommentRepository) ListThreads(blogID string, page, limit int) ([]models.Comment, int64, error) {
+	filter := bson.M{"blog_id": blogID, "depth": 0, "status": statusFilter(models.CommentApproved)}
+	totalCount, err := cr.collection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "comment_id", Value: 1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := cr.collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	comments := []models.Comment{}
+	if err := cursor.All(context.TODO(), &comments); err != nil {
+		return nil, 0, err
+	}
+	return comments, totalCount, nil
+}
+
+// ListReplies returns every approved reply in the given threads, oldest
+// first
+func (cr *CommentRepository) ListReplies(threadIDs []string) ([]models.Comment, error) {
+	filter := bson.M{"thread_id": bson.M{"$in": threadIDs}, "depth": bson.M{"$gt": 0}, "status": statusFilter(models.CommentApproved)}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "comment_id", Value: 1}})
+	cursor, err := cr.collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	comments := []models.Comment{}
+	if err := cursor.All(context.TODO(), &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// DeleteCommentsByBlogs removes the comments of deleted blogs
+func (cr *CommentRepository) DeleteCommentsByBlogs(blogIDs []string) error {
+	_, err := cr.collection.DeleteMany(context.TODO(), bson.M{"blog_id": bson.M{"$in": blogIDs}})
+	return err
+}
+
+// ListCommentedBlogIDs returns the blogs an author commented on
+func (cr *CommentRepository) ListCommentedBlogIDs(authorID string) ([]string, error) {
+	values, err := cr.collection.Distinct(context.TODO(), "blog_id", bson.M{"author_id": authorID})
+	if err != nil {
+		return nil, err
+	}
+	blogIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if blogID, ok := value.(string); ok {
+			blogIDs = append(blogIDs, blogID)
+		}
+	}
+	return blogIDs, nil
+}
+
+// ReassignComments moves every comment of an author to another one
+func (cr *CommentRepository) ReassignComments(fromAuthorID, toAuthorID string) error {
+	_, err := cr.collection.UpdateMany(context.TODO(), bson.M{"author_id": fromAuthorID}, bson.M{"$set": bson.M{"author_id": toAuthorID}})
+	return err
+}
+
+// SoftDeleteComment clears a comment's content and marks it deleted,
+// keeping it in place for its replies. It reports false when the comment
+// was already deleted.
+func (cr *CommentRepository) SoftDeleteComment(commentID string) (bool, error) {
+	result, err := cr.collection.UpdateOne(context.TODO(),
+		bson.M{"comment_id": commentID, "deleted": false},
+		bson.M{"$set": bson.M{"deleted": true, "content": ""}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// ListCommentsByStatus returns a page of live comments in status across all
+// blogs, oldest first, and their number.
+func (cr *CommentRepository) ListCommentsByStatus(status string, page, limit int) ([]models.Comment, int64, error) {
+	filter := bson.M{"status": statusFilter(status), "deleted": false}
+	totalCount, err := cr.collection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "comment_id", Value: 1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := cr.collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	comments := []models.Comment{}
+	if err := cursor.All(context.TODO(), &comments); err != nil {
+		return nil, 0, err
+	}
+	return comments, totalCount, nil
+}
+
+// SetCommentStatus moves a comment from one status to another. It reports
+// false when the comment was no longer in the from status or was deleted.
+func (cr *CommentRepository) SetCommentStatus(commentID, from, to string) (bool, error) {
+	result, err := cr.collection.UpdateOne(context.TODO(),
+		bson.M{"comment_id": commentID, "status": statusFilter(from), "deleted": false},
+		bson.M{"$set": bson.M{"status": to}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FollowRepository struct {
+	collection *mongo.Collection
+}
+
+func NewFollowRepository() *FollowRepository {
+	return &FollowRepository{
+		collection: db.DB.Collection("follows"),
+	}
+}
+
+func (fr *FollowRepository) EnsureIndexes() error {
+	_, err := fr.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "follower_id", Value: 1}, {Key: "type", Value: 1}, {Key: "target", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "target", Value: 1}}},
+	})
+	return err
+}
+
+// AddFollow reports false when the user already follows the target
+func (fr *FollowRepository) AddFollow(follow *models.Follow) (bool, error) {
+	_, err := fr.collection.InsertOne(context.TODO(), follow)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveFollow reports false when the user didn't follow the target
+func (fr *FollowRepository) RemoveFollow(followerID, followType, target string) (bool, error) {
+	result, err := fr.collection.DeleteOne(context.TODO(), bson.M{"follower_id": followerID, "type": followType, "target": target})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// ListFollowing returns what a user follows, oldest follow first
+func (fr *FollowRepository) ListFollowing(followerID string) ([]models.Follow, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := fr.collection.Find(context.TODO(), bson.M{"follower_id": followerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	follows := []models.Follow{}
+	if err := cursor.All(context.TODO(), &follows); err != nil {
+		return nil, err
+	}
+	return follows, nil
+}
+
+// ListFollowerIDs returns the users following an author or any of tags
+func (fr *FollowRepository) ListFollowerIDs(authorID string, tags []string) ([]string, error) {
+	targets := bson.A{bson.M{"type": models.FollowUser, "target": authorID}}
+	if len(tags) > 0 {
+		targets = append(targets, bson.M{"type": models.FollowTag, "target": bson.M{"$in": tags}})
+	}
+	values, err := fr.collection.Distinct(context.TODO(), "follower_id", bson.M{"$or": targets})
+	if err != nil {
+		return nil, err
+	}
+	followerIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if followerID, ok := value.(string); ok {
+			followerIDs = append(followerIDs, followerID)
+		}
+	}
+	return followerIDs, nil
+}
+
+// MoveTagFollows makes the followers of tag from follow tag to instead,
+// keeping when they first followed
+func (fr *FollowRepository) MoveTagFollows(from, to string) error {
+	filter := bson.M{"type": models.FollowTag, "target": from}
+	cursor, err := fr.collection.Find(context.TODO(), filter)
+	if err != nil {
+		return err
+	}
+	var follows []models.Follow
+	if err := cursor.All(context.TODO(), &follows); err != nil {
+		return err
+	}
+	if len(follows) == 0 {
+		return nil
+	}
+	// Users already following both keep their follow of to
+	writes := make([]mongo.WriteModel, len(follows))
+	for i, follow := range follows {
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"follower_id": follow.FollowerID, "type": models.FollowTag, "target": to}).
+			SetUpdate(bson.M{"$setOnInsert": bson.M{"created_at": follow.CreatedAt}}).
+			SetUpsert(true)
+	}
+	if _, err := fr.collection.BulkWrite(context.TODO(), writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return err
+	}
+	_, err = fr.collection.DeleteMany(context.TODO(), filter)
+	return err
+}
+
+// DeleteFollowsByUser removes both what a user follows and their followers
+func (fr *FollowRepository) DeleteFollowsByUser(userID string) error {
+	_, err := fr.collection.DeleteMany(context.TODO(), bson.M{"$or": bson.A{
+		bson.M{"follower_id": userID},
+		bson.M{"type": models.FollowUser, "target": userID},
+	}})
+	return err
+}
@@ -0,0 +1,48 @@
+package repositories
+
+// EnsureIndexes creates the indexes every collection relies on.
+// It must be called after db.ConnectMongo and Migrate.
+func EnsureIndexes() error {
+	if err := NewBlogRepository().EnsureIndexes(); err != nil {
+		return err
+	}
+	if err := NewTagRepository().EnsureIndexes(); err != nil {
+		return err
+	}
+	if err := NewCommentRepository().EnsureIndexes(); err != nil {
+		return err
+	}
+	if err := NewReactionRepository().EnsureIndexes(); err != nil {
+		return err
+	}
+	if err := NewBookmarkRepository().EnsureIndexes(); err != nil {
+		return err
+	}
+	if err := NewReadingListRepository().EnsureIndexes(); err != nil {
+		return err
+	}
+	if err := NewFollowRepository().EnsureIndexes(); err != nil {
+		return err
+	}
+	if err := NewViewRepository().EnsureIndexes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Migrate fixes up documents written by older versions: duplicate ids,
+// which would fail the unique indexes, tags in any case and missing
+// fields.
+func Migrate() error {
+	blogRepo := NewBlogRepository()
+	if err := blogRepo.DedupeBlogIDs(); err != nil {
+		return err
+	}
+	if err := blogRepo.NormalizeLegacyTags(); err != nil {
+		return err
+	}
+	if err := blogRepo.BackfillDefaults(); err != nil {
+		return err
+	}
+	return blogRepo.BackfillRenderedContent()
+}
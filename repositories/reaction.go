@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReactionRepository stores who reacted to what. Counts are kept in Redis
+// by the services and only copied to the blogs.
+type ReactionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewReactionRepository() *ReactionRepository {
+	return &ReactionRepository{
+		collection: db.DB.Collection("reactions"),
+	}
+}
+
+func (rr *ReactionRepository) EnsureIndexes() error {
+	_, err := rr.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		// One reaction of each kind per user and blog
+		{
+			Keys:    bson.D{{Key: "blog_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "reaction", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	return err
+}
+
+// AddReaction reports false when the user already reacted this way
+func (rr *ReactionRepository) AddReaction(reaction *models.Reaction) (bool, error) {
+	_, err := rr.collection.InsertOne(context.TODO(), reaction)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveReaction reports false when there was no such reaction
+func (rr *ReactionRepository) RemoveReaction(blogID, userID, reaction string) (bool, error) {
+	result, err := rr.collection.DeleteOne(context.TODO(), bson.M{"blog_id": blogID, "user_id": userID, "reaction": reaction})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// GetUserReactions lists the reactions a user left on a blog
+func (rr *ReactionRepository) GetUserReactions(blogID, userID string) ([]string, error) {
+	cursor, err := rr.collection.Find(context.TODO(), bson.M{"blog_id": blogID, "user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	var reactions []models.Reaction
+	if err := cursor.All(context.TODO(), &reactions); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(reactions))
+	for i := range reactions {
+		names[i] = reactions[i].Reaction
+	}
+	return names, nil
+}
+
+// CountReactions counts a blog's reactions by kind
+func (rr *ReactionRepository) CountReactions(blogID string) (map[string]int64, error) {
+	cursor, err := rr.collection.Aggregate(context.TODO(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"blog_id": blogID}}},
+		{{Key: "$group", Value: bson.M{"_id": "$reaction", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Reaction string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
+	if err := cursor.All(context.TODO(), &groups); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(groups))
+	for _, group := range groups {
+		counts[group.Reaction] = group.Count
+	}
+	return counts, nil
+}
+
+// DeleteReactionsByBlogs removes the reactions to deleted blogs
+func (rr *ReactionRepository) DeleteReactionsByBlogs(blogIDs []string) error {
+	_, err := rr.collection.DeleteMany(context.TODO(), bson.M{"blog_id": bson.M{"$in": blogIDs}})
+	return err
+}
+
+// DeleteReactionsByUser removes every reaction of a user and returns them
+// so the counts can be adjusted
+func (rr *ReactionRepository) DeleteReactionsByUser(userID string) ([]models.Reaction, error) {
+	cursor, err := rr.collection.Find(context.TODO(), bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	var reactions []models.Reaction
+	if err := cursor.All(context.TODO(), &reactions); err != nil {
+		return nil, err
+	}
+	// Only delete what was read, so every deleted reaction is accounted for
+	ids := make(bson.A, len(reactions))
+	for i := range reactions {
+		ids[i] = reactions[i].ID
+	}
+	if len(ids) > 0 {
+		if _, err := rr.collection.DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			return nil, err
+		}
+	}
+	return reactions, nil
+}
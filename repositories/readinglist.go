@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"context"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BookmarkRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBookmarkRepository() *BookmarkRepository {
+	return &BookmarkRepository{
+		collection: db.DB.Collection("bookmarks"),
+	}
+}
+
+func (br *BookmarkRepository) EnsureIndexes() error {
+	_, err := br.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "blog_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "blog_id", Value: 1}}},
+	})
+	return err
+}
+
+// AddBookmark reports false when the blog was already bookmarked
+func (br *BookmarkRepository) AddBookmark(bookmark *models.Bookmark) (bool, error) {
+	_, err := br.collection.InsertOne(context.TODO(), bookmark)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (br *BookmarkRepository) RemoveBookmark(userID, blogID string) error {
+	_, err := br.collection.DeleteOne(context.TODO(), bson.M{"user_id": userID, "blog_id": blogID})
+	return err
+}
+
+// ListBookmarks returns a page of a user's bookmarks, newest first, and
+// their number
+func (br *BookmarkRepository) ListBookmarks(userID string, page, limit int) ([]models.Bookmark, int64, error) {
+	filter := bson.M{"user_id": userID}
+	totalCount, err := br.collection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "blog_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := br.collection.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	bookmarks := []models.Bookmark{}
+	if err := cursor.All(context.TODO(), &bookmarks); err != nil {
+		return nil, 0, err
+	}
+	return bookmarks, totalCount, nil
+}
+
+func (br *BookmarkRepository) DeleteBookmarksByBlogs(blogIDs []string) error {
+	_, err := br.collection.DeleteMany(context.TODO(), bson.M{"blog_id": bson.M{"$in": blogIDs}})
+	return err
+}
+
+func (br *BookmarkRepository) DeleteBookmarksByUser(userID string) error {
+	_, err := br.collection.DeleteMany(context.TODO(), bson.M{"user_id": userID})
+	return err
+}
+
+type ReadingListRepository struct {
+	collection *mongo.Collection
+}
+
+func NewReadingListRepository() *ReadingListRepository {
+	return &ReadingListRepository{
+		collection: db.DB.Collection("reading_lists"),
+	}
+}
+
+func (rr *ReadingListRepository) EnsureIndexes() error {
+	_, err := rr.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "list_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "share_token", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "blog_ids", Value: 1}}},
+	})
+	return err
+}
+
+func (rr *ReadingListRepository) InsertList(list *models.ReadingList) error {
+	_, err := rr.collection.InsertOne(context.TODO(), list)
+	return err
+}
+
+func (rr *ReadingListRepository) GetList(listID string) (*models.ReadingList, error) {
+	return rr.findOne(bson.M{"list_id": listID})
+}
+
+func (rr *ReadingListRepository) GetListByShareToken(token string) (*models.ReadingList, error) {
+	return rr.findOne(bson.M{"share_token": token})
+}
+
+func (rr *ReadingListRepository) findOne(filter bson.M) (*models.ReadingList, error) {
+	var list models.ReadingList
+	err := rr.collection.FindOne(context.TODO(), filter).Decode(&list)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ListsByUser returns a user's lists, oldest first
+func (rr *ReadingListRepository) ListsByUser(userID string) ([]models.ReadingList, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := rr.collection.Find(context.TODO(), bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	lists := []models.ReadingList{}
+	if err := cursor.All(context.TODO(), &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (rr *ReadingListRepository) UpdateListFields(listID string, fields bson.M) error {
+	_, err := rr.collection.UpdateOne(context.TODO(), bson.M{"list_id": listID}, bson.M{"$set": fields})
+	return err
+}
+
+// AddItem appends a blog to a list unless it is already there or the list
+// is full, and reports whether it was added
+func (rr *ReadingListRepository) AddItem(listID, blogID string) (bool, error) {
+	filter := bson.M{
+		"list_id":  listID,
+		"blog_ids": bson.M{"$ne": blogID},
+		"blog_ids." + strconv.Itoa(models.MaxReadingListItems-1): bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$push":        bson.M{"blog_ids": blogID},
+		"$currentDate": bson.M{"updated_at": true},
+	}
+	result, err := rr.collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (rr *ReadingListRepository) RemoveItem(listID, blogID string) error {
+	_, err := rr.collection.UpdateOne(context.TODO(), bson.M{"list_id": listID}, bson.M{
+		"$pull":        bson.M{"blog_ids": blogID},
+		"$currentDate": bson.M{"updated_at": true},
+	})
+	return err
+}
+
+// ReorderItems replaces the order of a list's blogs. It reports false when
+// blogIDs no longer hold exactly the list's blogs.
+func (rr *ReadingListRepository) ReorderItems(listID string, blogIDs []string) (bool, error) {
+	filter := bson.M{"list_id": listID, "blog_ids": bson.M{"$size": len(blogIDs)}}
+	if len(blogIDs) > 0 {
+		filter["blog_ids"] = bson.M{"$size": len(blogIDs), "$all": blogIDs}
+	}
+	result, err := rr.collection.UpdateOne(context.TODO(), filter, bson.M{
+		"$set":         bson.M{"blog_ids": blogIDs},
+		"$currentDate": bson.M{"updated_at": true},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (rr *ReadingListRepository) DeleteList(listID string) error {
+	_, err := rr.collection.DeleteOne(context.TODO(), bson.M{"list_id": listID})
+	return err
+}
+
+func (rr *ReadingListRepository) DeleteListsByUser(userID string) error {
+	_, err := rr.collection.DeleteMany(context.TODO(), bson.M{"user_id": userID})
+	return err
+}
+
+// RemoveBlogsFromLists takes deleted blogs out of every list
+func (rr *ReadingListRepository) RemoveBlogsFromLists(blogIDs []string) error {
+	_, err := rr.collection.UpdateMany(context.TODO(),
+		bson.M{"blog_ids": bson.M{"$in": blogIDs}},
+		bson.M{"$pull": bson.M{"blog_ids": bson.M{"$in": blogIDs}}},
+	)
+	return err
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type TagRepository struct {
+	collection *mongo.Collection
+}
+
+func NewTagRepository() *TagRepository {
+	return &TagRepository{
+		collection: db.DB.Collection("tags"),
+	}
+}
+
+func (tr *TagRepository) EnsureIndexes() error {
+	_, err := tr.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "aliases", Value: 1}},
+		},
+	})
+	return err
+}
+
+func (tr *TagRepository) ListTags() ([]models.Tag, error) {
+	var tags []models.Tag
+	cursor, err := tr.collection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetTag finds a tag by its canonical name or one of its aliases.
+func (tr *TagRepository) GetTag(name string) (*models.Tag, error) {
+	var tag models.Tag
+	filter := bson.M{"$or": bson.A{bson.M{"name": name}, bson.M{"aliases": name}}}
+	err := tr.collection.FindOne(context.TODO(), filter).Decode(&tag)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// ResolveAliases maps every alias among names to its canonical tag name.
+func (tr *TagRepository) ResolveAliases(names []string) (map[string]string, error) {
+	resolved := map[string]string{}
+	if len(names) == 0 {
+		return resolved, nil
+	}
+	var tags []models.Tag
+	cursor, err := tr.collection.Find(context.TODO(), bson.M{"aliases": bson.M{"$in": names}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(context.TODO(), &tags); err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		for _, alias := range tag.Aliases {
+			resolved[alias] = tag.Name
+		}
+	}
+	return resolved, nil
+}
+
+func (tr *TagRepository) UpsertTag(tag *models.Tag) error {
+	_, err := tr.collection.UpdateOne(context.TODO(),
+		bson.M{"name": tag.Name},
+		bson.M{
+			"$set": bson.M{
+				"description": tag.Description,
+				"aliases":     tag.Aliases,
+				"updated_at":  tag.UpdatedAt,
+			},
+			"$setOnInsert": bson.M{"created_at": tag.CreatedAt},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (tr *TagRepository) DeleteTag(name string) error {
+	_, err := tr.collection.DeleteOne(context.TODO(), bson.M{"name": name})
+	return err
+}
@@ -3,11 +3,16 @@ package repositories
 import (
 	"context"
 	"errors"
+	"fmt"
 	"inkinkink111/go-blog-management/db"
 	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/utils"
+	"strconv"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type UserRepository struct {
@@ -46,6 +51,40 @@ func (ur *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+// GetUsersByIDs loads many users in one query, without their passwords
+func (ur *UserRepository) GetUsersByIDs(userIDs []string) ([]models.User, error) {
+	opts := options.Find().SetProjection(bson.M{"password": 0})
+	cursor, err := ur.collection.Find(context.TODO(), bson.M{"user_id": bson.M{"$in": userIDs}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(context.TODO(), &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateUserFields sets the given fields of a user
+func (ur *UserRepository) UpdateUserFields(userID string, fields bson.M) error {
+	_, err := ur.collection.UpdateOne(context.TODO(), bson.M{"user_id": userID}, bson.M{"$set": fields})
+	return err
+}
+
+func (ur *UserRepository) GetUserByUserID(userID string) (*models.User, error) {
+	filter := bson.M{"user_id": userID}
+	result := ur.collection.FindOne(context.TODO(), filter)
+	var user models.User
+	if err := result.Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 // func (db *userRepo) InsertUser(data models.User) error {
 // 	// Check if user already exists
 // 	filter := bson.M{"email": data.Email}
@@ -64,3 +103,103 @@ func (ur *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 
 // 	return nil
 // }
+
+func (ur *UserRepository) GetUserByVerificationHash(hash string) (*models.User, error) {
+	filter := bson.M{"email_verification_hash": hash}
+	result := ur.collection.FindOne(context.TODO(), filter)
+	var user models.User
+	if err := result.Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// MarkDeleting records the deletion of an account and logs it out
+// everywhere, before any of its data is touched
+func (ur *UserRepository) MarkDeleting(userID string, deletion models.AccountDeletion) error {
+	if err := ur.UpdateUserFields(userID, bson.M{"deletion": deletion}); err != nil {
+		return err
+	}
+	db.RedisClient.Set(context.Background(), tokensValidAfterKey(userID), deletedUserMarker, utils.TokenLifetime)
+	return nil
+}
+
+// ListDeletingUsers returns the accounts whose deletion hasn't finished
+func (ur *UserRepository) ListDeletingUsers() ([]models.User, error) {
+	cursor, err := ur.collection.Find(context.TODO(), bson.M{"deletion": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(context.TODO(), &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (ur *UserRepository) DeleteUser(userID string) error {
+	if _, err := ur.collection.DeleteOne(context.TODO(), bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	// Tokens of deleted users must stop working right away
+	db.RedisClient.Set(context.Background(), tokensValidAfterKey(userID), deletedUserMarker, utils.TokenLifetime)
+	return nil
+}
+
+// Cached in Redis as a unix time, or deletedUserMarker
+const deletedUserMarker = "deleted"
+
+func tokensValidAfterKey(userID string) string {
+	return fmt.Sprintf("user:tokens_valid_after:%s", userID)
+}
+
+// RevokeTokens invalidates every token of the user issued before at
+func (ur *UserRepository) RevokeTokens(userID string, at time.Time) error {
+	at = at.Truncate(time.Second)
+	if err := ur.UpdateUserFields(userID, bson.M{"tokens_valid_after": at}); err != nil {
+		return err
+	}
+	db.RedisClient.Set(context.Background(), tokensValidAfterKey(userID), at.Unix(), utils.TokenLifetime)
+	return nil
+}
+
+// TokensValidAfter returns when the user's tokens were last revoked, and
+// false when the user no longer exists or is being deleted. It is read on every authenticated
+// request so it is cached for the lifetime of a token.
+func (ur *UserRepository) TokensValidAfter(userID string) (time.Time, bool, error) {
+	key := tokensValidAfterKey(userID)
+	if cached, err := db.RedisClient.Get(context.Background(), key).Result(); err == nil {
+		if cached == deletedUserMarker {
+			return time.Time{}, false, nil
+		}
+		if unix, err := strconv.ParseInt(cached, 10, 64); err == nil {
+			return unixTime(unix), true, nil
+		}
+	}
+	user, err := ur.GetUserByUserID(userID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if user == nil || user.Deletion != nil {
+		db.RedisClient.Set(context.Background(), key, deletedUserMarker, utils.TokenLifetime)
+		return time.Time{}, false, nil
+	}
+	var validAfter int64
+	if !user.TokensValidAfter.IsZero() {
+		validAfter = user.TokensValidAfter.Unix()
+	}
+	db.RedisClient.Set(context.Background(), key, validAfter, utils.TokenLifetime)
+	return unixTime(validAfter), true, nil
+}
+
+// unixTime maps 0, never revoked, to the zero time
+func unixTime(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ViewRepository stores flushed view counts: one document per blog and
+// day, and one per blog, day and referrer. Dates are UTC days formatted
+// as 2006-01-02 so they sort as strings.
+type ViewRepository struct {
+	views     *mongo.Collection
+	referrers *mongo.Collection
+}
+
+func NewViewRepository() *ViewRepository {
+	return &ViewRepository{
+		views:     db.DB.Collection("blog_views"),
+		referrers: db.DB.Collection("blog_referrers"),
+	}
+}
+
+func (vr *ViewRepository) EnsureIndexes() error {
+	_, err := vr.views.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "blog_id", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = vr.referrers.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "blog_id", Value: 1}, {Key: "date", Value: 1}, {Key: "referrer", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// AddDailyViews adds views to a blog's day. uniques is the day's unique
+// count so far and only ever raises the stored one.
+func (vr *ViewRepository) AddDailyViews(blogID, date string, views, uniques int64) error {
+	_, err := vr.views.UpdateOne(context.TODO(),
+		bson.M{"blog_id": blogID, "date": date},
+		bson.M{"$inc": bson.M{"views": views}, "$max": bson.M{"uniques": uniques}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (vr *ViewRepository) AddReferrerViews(blogID, date, referrer string, views int64) error {
+	_, err := vr.referrers.UpdateOne(context.TODO(),
+		bson.M{"blog_id": blogID, "date": date, "referrer": referrer},
+		bson.M{"$inc": bson.M{"views": views}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// DailyViews sums the views of blogs per day between from and to included.
+// Days without views are left out.
+func (vr *ViewRepository) DailyViews(blogIDs []string, from, to string) ([]models.DailyViews, error) {
+	days := []models.DailyViews{}
+	err := vr.aggregate(vr.views, &days, mongo.Pipeline{
+		{{Key: "$match", Value: rangeFilter(blogIDs, from, to)}},
+		{{Key: "$group", Value: bson.M{"_id": "$date", "views": bson.M{"$sum": "$views"}, "uniques": bson.M{"$sum": "$uniques"}}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "date": "$_id", "views": 1, "uniques": 1}}},
+		{{Key: "$sort", Value: bson.M{"date": 1}}},
+	})
+	return days, err
+}
+
+// TopReferrers returns the referrers sending the most views to blogs
+// between from and to included
+func (vr *ViewRepository) TopReferrers(blogIDs []string, from, to string, limit int) ([]models.ReferrerViews, error) {
+	referrers := []models.ReferrerViews{}
+	err := vr.aggregate(vr.referrers, &referrers, mongo.Pipeline{
+		{{Key: "$match", Value: rangeFilter(blogIDs, from, to)}},
+		{{Key: "$group", Value: bson.M{"_id": "$referrer", "views": bson.M{"$sum": "$views"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "views", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "referrer": "$_id", "views": 1}}},
+	})
+	return referrers, err
+}
+
+// TopPosts returns the most viewed of blogs between from and to included
+func (vr *ViewRepository) TopPosts(blogIDs []string, from, to string, limit int) ([]models.PostViews, error) {
+	posts := []models.PostViews{}
+	err := vr.aggregate(vr.views, &posts, mongo.Pipeline{
+		{{Key: "$match", Value: rangeFilter(blogIDs, from, to)}},
+		{{Key: "$group", Value: bson.M{"_id": "$blog_id", "views": bson.M{"$sum": "$views"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "views", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "blog_id": "$_id", "views": 1}}},
+	})
+	return posts, err
+}
+
+func (vr *ViewRepository) aggregate(collection *mongo.Collection, results any, pipeline mongo.Pipeline) error {
+	cursor, err := collection.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(context.TODO(), results)
+}
+
+func rangeFilter(blogIDs []string, from, to string) bson.M {
+	return bson.M{"blog_id": bson.M{"$in": blogIDs}, "date": bson.M{"$gte": from, "$lte": to}}
+}
+
+// DeleteViewsByBlogs removes the counts of deleted blogs
+func (vr *ViewRepository) DeleteViewsByBlogs(blogIDs []string) error {
+	filter := bson.M{"blog_id": bson.M{"$in": blogIDs}}
+	if _, err := vr.views.DeleteMany(context.TODO(), filter); err != nil {
+		return err
+	}
+	_, err := vr.referrers.DeleteMany(context.TODO(), filter)
+	return err
+}
@@ -1,7 +1,9 @@
 package routes
 
 import (
+	"inkinkink111/go-blog-management/config"
 	"inkinkink111/go-blog-management/middleware"
+	"inkinkink111/go-blog-management/models"
 	"inkinkink111/go-blog-management/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,15 +14,146 @@ func SetupRoutes(app *fiber.App) {
 		return c.JSON(fiber.Map{"message": "Blog API is running!"})
 	})
 
-	v1 := app.Group("/api/v1")
+	setupFeeds(app)
+	setupV1(app)
+	setupV2(app)
+}
+
+// setupFeeds serves syndication feeds and sitemaps at the site root, where
+// feed readers and crawlers look for them
+func setupFeeds(app *fiber.App) {
+	for _, prefix := range []string{"", "/tags/:name", "/users/:user_id"} {
+		app.Get(prefix+"/feed.xml", services.RSSFeed)
+		app.Get(prefix+"/atom.xml", services.AtomFeed)
+		app.Get(prefix+"/feed.json", services.JSONFeed)
+	}
+	app.Get("/sitemap.xml", services.GetSitemap)
+	app.Get("/sitemaps/:page.xml", services.GetSitemapPage)
+}
+
+// setupV1 keeps the original RPC-style routes working until their sunset
+func setupV1(app *fiber.App) {
+	deprecatedAt, sunsetAt := config.V1Deprecation()
+	v1 := app.Group("/api/v1", middleware.APIVersion("v1"), middleware.Deprecated(deprecatedAt, sunsetAt, "/api/v2"))
 	v1.Post("/register", services.Register)
 	v1.Post("/login", services.Login)
 	v1.Get("/all_blogs", services.GetAllBlogs)
-	v1.Get("/blog/:blog_id", services.GetBlogByID)
+	v1.Get("/blogs/trending", services.GetTrendingBlogs)
+	v1.Get("/blog/:blog_id", middleware.OptionalAuthenticate, services.GetBlogByID)
+	v1.Get("/users/:user_id", services.GetUserProfile)
+	v1.Get("/users/:user_id/blogs", services.GetUserBlogs)
+	v1.Get("/blogs/:blog_id/comments", middleware.OptionalAuthenticate, services.GetComments)
+	v1.Get("/blogs/:blog_id/related", middleware.OptionalAuthenticate, services.GetRelatedBlogs)
+	v1.Post("/email/verify", services.VerifyEmail)
+	v1.Get("/shared-lists/:token", services.GetSharedReadingList)
+	v1.Get("/search", services.SearchBlogs)
+	v1.Get("/tags", services.GetTags)
+	v1.Get("/tags/autocomplete", services.AutocompleteTags)
 
 	auth := v1.Group("/")
 	auth.Use(middleware.Authenticate)
 	auth.Post("/create_blog", services.CreateBlog)
 	auth.Delete("/delete_blog/:blog_id", services.DeleteBlog)
 	auth.Put("/update_blog/:blog_id", services.UpdateBlog)
+	auth.Patch("/blogs/:blog_id", services.PatchBlog)
+	auth.Post("/blogs/:blog_id/comments", services.CreateComment)
+	auth.Put("/blogs/:blog_id/reactions/:reaction", services.AddReaction)
+	auth.Delete("/blogs/:blog_id/reactions/:reaction", services.RemoveReaction)
+	auth.Patch("/comments/:comment_id", services.UpdateComment)
+	auth.Delete("/comments/:comment_id", services.DeleteComment)
+	auth.Get("/me/bookmarks", services.GetBookmarks)
+	auth.Put("/me/bookmarks/:blog_id", services.AddBookmark)
+	auth.Delete("/me/bookmarks/:blog_id", services.RemoveBookmark)
+	auth.Get("/me/lists", services.GetReadingLists)
+	auth.Post("/me/lists", services.CreateReadingList)
+	auth.Get("/me/lists/:list_id", services.GetReadingList)
+	auth.Patch("/me/lists/:list_id", services.UpdateReadingList)
+	auth.Delete("/me/lists/:list_id", services.DeleteReadingList)
+	auth.Put("/me/lists/:list_id/items", services.ReorderReadingList)
+	auth.Put("/me/lists/:list_id/items/:blog_id", services.AddReadingListItem)
+	auth.Delete("/me/lists/:list_id/items/:blog_id", services.RemoveReadingListItem)
+	auth.Get("/me/following", services.GetFollowing)
+	auth.Put("/me/following/users/:user_id", services.FollowUser)
+	auth.Delete("/me/following/users/:user_id", services.UnfollowUser)
+	auth.Put("/me/following/tags/:name", services.FollowTag)
+	auth.Delete("/me/following/tags/:name", services.UnfollowTag)
+	auth.Get("/feed", services.GetFeed)
+	auth.Get("/me/analytics", services.GetAnalytics)
+	auth.Get("/me", services.GetMe)
+	auth.Patch("/me", services.UpdateMe)
+	auth.Delete("/me", services.DeleteAccount)
+	auth.Post("/me/password", services.ChangePassword)
+	auth.Post("/me/email", services.ChangeEmail)
+
+	editorOnly := middleware.RequireRole(models.RoleEditor, models.RoleAdmin)
+	auth.Put("/tags/:name", editorOnly, services.UpsertTag)
+	auth.Post("/tags/:name/rename", editorOnly, services.RenameTag)
+	auth.Post("/tags/:name/merge", editorOnly, services.MergeTag)
+	auth.Get("/moderation/comments", editorOnly, services.GetModerationQueue)
+	auth.Post("/moderation/comments/:comment_id/:action", editorOnly, services.ModerateComment)
+	auth.Put("/moderation/users/:user_id/shadow-ban", editorOnly, services.ShadowBanUser)
+	auth.Delete("/moderation/users/:user_id/shadow-ban", editorOnly, services.LiftShadowBan)
+}
+
+// setupV2 exposes the same services as resources
+func setupV2(app *fiber.App) {
+	v2 := app.Group("/api/v2", middleware.APIVersion("v2"))
+	v2.Post("/users", services.Register)
+	v2.Post("/sessions", services.Login)
+	v2.Get("/blogs", services.GetAllBlogs)
+	v2.Get("/blogs/trending", services.GetTrendingBlogs)
+	v2.Get("/blogs/:blog_id", middleware.OptionalAuthenticate, services.GetBlogByID)
+	v2.Get("/users/:user_id", services.GetUserProfile)
+	v2.Get("/users/:user_id/blogs", services.GetUserBlogs)
+	v2.Get("/blogs/:blog_id/comments", middleware.OptionalAuthenticate, services.GetComments)
+	v2.Get("/blogs/:blog_id/related", middleware.OptionalAuthenticate, services.GetRelatedBlogs)
+	v2.Post("/email-verifications", services.VerifyEmail)
+	v2.Get("/shared-lists/:token", services.GetSharedReadingList)
+	v2.Get("/search", services.SearchBlogs)
+	v2.Get("/tags", services.GetTags)
+	v2.Get("/tags/autocomplete", services.AutocompleteTags)
+
+	auth := v2.Group("/")
+	auth.Use(middleware.Authenticate)
+	auth.Post("/blogs", services.CreateBlog)
+	auth.Put("/blogs/:blog_id", services.UpdateBlog)
+	auth.Patch("/blogs/:blog_id", services.PatchBlog)
+	auth.Delete("/blogs/:blog_id", services.DeleteBlog)
+	auth.Post("/blogs/:blog_id/comments", services.CreateComment)
+	auth.Put("/blogs/:blog_id/reactions/:reaction", services.AddReaction)
+	auth.Delete("/blogs/:blog_id/reactions/:reaction", services.RemoveReaction)
+	auth.Patch("/comments/:comment_id", services.UpdateComment)
+	auth.Delete("/comments/:comment_id", services.DeleteComment)
+	auth.Get("/me/bookmarks", services.GetBookmarks)
+	auth.Put("/me/bookmarks/:blog_id", services.AddBookmark)
+	auth.Delete("/me/bookmarks/:blog_id", services.RemoveBookmark)
+	auth.Get("/me/lists", services.GetReadingLists)
+	auth.Post("/me/lists", services.CreateReadingList)
+	auth.Get("/me/lists/:list_id", services.GetReadingList)
+	auth.Patch("/me/lists/:list_id", services.UpdateReadingList)
+	auth.Delete("/me/lists/:list_id", services.DeleteReadingList)
+	auth.Put("/me/lists/:list_id/items", services.ReorderReadingList)
+	auth.Put("/me/lists/:list_id/items/:blog_id", services.AddReadingListItem)
+	auth.Delete("/me/lists/:list_id/items/:blog_id", services.RemoveReadingListItem)
+	auth.Get("/me/following", services.GetFollowing)
+	auth.Put("/me/following/users/:user_id", services.FollowUser)
+	auth.Delete("/me/following/users/:user_id", services.UnfollowUser)
+	auth.Put("/me/following/tags/:name", services.FollowTag)
+	auth.Delete("/me/following/tags/:name", services.UnfollowTag)
+	auth.Get("/feed", services.GetFeed)
+	auth.Get("/me/analytics", services.GetAnalytics)
+	auth.Get("/me", services.GetMe)
+	auth.Patch("/me", services.UpdateMe)
+	auth.Delete("/me", services.DeleteAccount)
+	auth.Put("/me/password", services.ChangePassword)
+	auth.Put("/me/email", services.ChangeEmail)
+
+	editorOnly := middleware.RequireRole(models.RoleEditor, models.RoleAdmin)
+	auth.Put("/tags/:name", editorOnly, services.UpsertTag)
+	auth.Post("/tags/:name/rename", editorOnly, services.RenameTag)
+	auth.Post("/tags/:name/merge", editorOnly, services.MergeTag)
+	auth.Get("/moderation/comments", editorOnly, services.GetModerationQueue)
+	auth.Post("/moderation/comments/:comment_id/:action", editorOnly, services.ModerateComment)
+	auth.Put("/moderation/users/:user_id/shadow-ban", editorOnly, services.ShadowBanUser)
+	auth.Delete("/moderation/users/:user_id/shadow-ban", editorOnly, services.LiftShadowBan)
 }
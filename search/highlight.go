@@ -0,0 +1,118 @@
+package search
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const snippetLength = 200
+
+// Highlight HTML-escapes text and wraps query matches in <mark> tags.
+func Highlight(text string, q Query) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range q.matches(text) {
+		sb.WriteString(html.EscapeString(text[last:loc[0]]))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(text[loc[0]:loc[1]]))
+		sb.WriteString("</mark>")
+		last = loc[1]
+	}
+	sb.WriteString(html.EscapeString(text[last:]))
+	return sb.String()
+}
+
+// Snippet cuts a window of plain text around the first match and
+// highlights it.
+func Snippet(text string, q Query) string {
+	start, matchStart := 0, 0
+	if matches := q.matches(text); len(matches) > 0 {
+		matchStart = matches[0][0]
+		start = max(matchStart-snippetLength/4, 0)
+	}
+	// Never cut a character in two, then snap to word boundaries when
+	// the text has spaces
+	start = runeStart(text, start)
+	if start > 0 {
+		if i := strings.IndexFunc(text[start:matchStart], unicode.IsSpace); i >= 0 {
+			_, size := utf8.DecodeRuneInString(text[start+i:])
+			start += i + size
+		}
+	}
+	end := len(text)
+	if end-start > snippetLength {
+		end = runeStart(text, start+snippetLength)
+		if i := strings.LastIndexFunc(text[start:end], unicode.IsSpace); i > 0 {
+			end = start + i
+		}
+	}
+	snippet := Highlight(text[start:end], q)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// runeStart moves i back to the first byte of the character it falls in
+func runeStart(text string, i int) int {
+	for i > 0 && i < len(text) && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
+
+// matches returns the byte ranges of the query's terms and phrases that
+// stand as whole words in text
+func (q Query) matches(text string) [][]int {
+	pattern := q.pattern()
+	if pattern == nil {
+		return nil
+	}
+	var matches [][]int
+	for _, loc := range pattern.FindAllStringIndex(text, -1) {
+		if wordBoundary(text, loc[0]) && wordBoundary(text, loc[1]) {
+			matches = append(matches, loc)
+		}
+	}
+	return matches
+}
+
+// wordBoundary reports whether a word may start or end at byte i of text.
+// Scripts written without spaces have a boundary around every character.
+func wordBoundary(text string, i int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:i])
+	after, _ := utf8.DecodeRuneInString(text[i:])
+	return !inWord(before) || !inWord(after)
+}
+
+func inWord(r rune) bool {
+	if !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+		return false
+	}
+	return !unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai)
+}
+
+func (q Query) pattern() *regexp.Regexp {
+	var alternatives []string
+	for _, phrase := range q.Phrases {
+		words := make([]string, len(phrase))
+		for i, word := range phrase {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		// Words may be separated by punctuation and up to two stop words
+		alternatives = append(alternatives, strings.Join(words, `[^\p{L}\p{N}]+(?:[\p{L}\p{N}]+[^\p{L}\p{N}]+){0,2}?`))
+	}
+	for _, term := range q.Terms {
+		alternatives = append(alternatives, regexp.QuoteMeta(term))
+	}
+	if len(alternatives) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(?:` + strings.Join(alternatives, "|") + `)`)
+}
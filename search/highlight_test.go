@@ -0,0 +1,79 @@
+package search_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"inkinkink111/go-blog-management/search"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{"term", "Learning Go today", "go", "Learning <mark>Go</mark> today"},
+		{"whole words only", "Going to go", "go", "Going to <mark>go</mark>"},
+		{"every match", "go, Go and GO", "go", "<mark>go</mark>, <mark>Go</mark> and <mark>GO</mark>"},
+		{"escapes html", "<b>go</b> & more", "go", "&lt;b&gt;<mark>go</mark>&lt;/b&gt; &amp; more"},
+		{"escapes without match", `<script>alert("x")</script>`, "go", "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;"},
+		{"phrase", "Error handling in Go", `"error handling"`, "<mark>Error handling</mark> in Go"},
+		{"phrase across stop words", "the end of the road", `"end road"`, "the <mark>end of the road</mark>"},
+		{"accented word", "Un café crème", "café", "Un <mark>café</mark> crème"},
+		{"accented word is one word", "Un cafés", "caf", "Un cafés"},
+		{"cyrillic", "Привет мир", "мир", "Привет <mark>мир</mark>"},
+		{"han inside a sentence", "我在東京工作", "東京", "我在<mark>東京</mark>工作"},
+		{"kana inside a sentence", "きょうはカレーです", "カレー", "きょうは<mark>カレー</mark>です"},
+		{"no terms", "Learning Go", "-go", "Learning Go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := search.Highlight(tt.text, search.ParseQuery(tt.query)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	filler := strings.Repeat("lorem ipsum dolor ", 30)
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		mark     string
+		leading  bool
+		trailing bool
+	}{
+		{"short text", "Learning Go today", "go", "<mark>Go</mark>", false, false},
+		{"match at the start", "Go " + filler, "go", "<mark>Go</mark>", false, true},
+		{"match in the middle", filler + "gopher " + filler, "gopher", "<mark>gopher</mark>", true, true},
+		{"match at the end", filler + "gopher", "gopher", "<mark>gopher</mark>", true, false},
+		{"no match", filler, "gopher", "", false, true},
+		{"multibyte text", strings.Repeat("日本語のテキスト", 40) + "東京" + strings.Repeat("日本語のテキスト", 40), "東京", "<mark>東京</mark>", true, true},
+		{"accented text", strings.Repeat("é", 300) + " café " + strings.Repeat("é", 300), "café", "<mark>café</mark>", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := search.Snippet(tt.text, search.ParseQuery(tt.query))
+			if !utf8.ValidString(got) {
+				t.Fatalf("snippet is not valid UTF-8: %q", got)
+			}
+			if tt.mark != "" && !strings.Contains(got, tt.mark) {
+				t.Errorf("snippet %q is missing %q", got, tt.mark)
+			}
+			if strings.HasPrefix(got, "…") != tt.leading {
+				t.Errorf("snippet %q: leading ellipsis is %v, want %v", got, !tt.leading, tt.leading)
+			}
+			if strings.HasSuffix(got, "…") != tt.trailing {
+				t.Errorf("snippet %q: trailing ellipsis is %v, want %v", got, !tt.trailing, tt.trailing)
+			}
+			if plain := strings.NewReplacer("<mark>", "", "</mark>", "", "…", "").Replace(got); utf8.RuneCountInString(plain) > 200 {
+				t.Errorf("snippet is %d characters long, want at most 200", utf8.RuneCountInString(plain))
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/utils"
+)
+
+const (
+	fieldTitle = iota
+	fieldTags
+	fieldContent
+	fieldCount
+)
+
+// Same weights as the MongoDB text index
+var fieldWeights = [fieldCount]float64{10, 5, 1}
+
+type document struct {
+	fields    [fieldCount][]string
+	createdAt time.Time
+}
+
+type Hit struct {
+	BlogID string
+	Score  float64
+}
+
+// MemoryIndex is an in-process inverted index over blogs, used instead of
+// the MongoDB text index when SEARCH_BACKEND=memory.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string]*[fieldCount]int
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     map[string]*document{},
+		postings: map[string]map[string]*[fieldCount]int{},
+	}
+}
+
+// Add indexes a blog, replacing any previous version of it.
+func (idx *MemoryIndex) Add(blog *models.Blog) {
+	content := blog.Content
+	if blog.ContentHTML != "" {
+		content = utils.PlainText(blog.ContentHTML)
+	}
+	doc := &document{createdAt: blog.CreatedAt}
+	doc.fields[fieldTitle] = Tokenize(blog.Title)
+	doc.fields[fieldTags] = Tokenize(strings.Join(blog.Tags, " "))
+	doc.fields[fieldContent] = Tokenize(content)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(blog.BlogID)
+	idx.docs[blog.BlogID] = doc
+	for field, tokens := range doc.fields {
+		for _, token := range tokens {
+			posting, ok := idx.postings[token]
+			if !ok {
+				posting = map[string]*[fieldCount]int{}
+				idx.postings[token] = posting
+			}
+			tf, ok := posting[blog.BlogID]
+			if !ok {
+				tf = &[fieldCount]int{}
+				posting[blog.BlogID] = tf
+			}
+			tf[field]++
+		}
+	}
+}
+
+func (idx *MemoryIndex) Remove(blogID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(blogID)
+}
+
+func (idx *MemoryIndex) remove(blogID string) {
+	doc, ok := idx.docs[blogID]
+	if !ok {
+		return
+	}
+	for _, tokens := range doc.fields {
+		for _, token := range tokens {
+			if posting, ok := idx.postings[token]; ok {
+				delete(posting, blogID)
+				if len(posting) == 0 {
+					delete(idx.postings, token)
+				}
+			}
+		}
+	}
+	delete(idx.docs, blogID)
+}
+
+// Search ranks blogs by TF-IDF with field weights. Any term may match,
+// but every phrase must appear and no excluded term or phrase may.
+func (idx *MemoryIndex) Search(q Query, offset, limit int) ([]Hit, int) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	terms := append([]string{}, q.Terms...)
+	for _, phrase := range q.Phrases {
+		terms = append(terms, phrase...)
+	}
+	scores := map[string]float64{}
+	total := float64(len(idx.docs))
+	for _, term := range terms {
+		posting := idx.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		idf := math.Log(1 + total/float64(len(posting)))
+		for blogID, tf := range posting {
+			weighted := 0.0
+			for field, count := range tf {
+				weighted += fieldWeights[field] * float64(count)
+			}
+			scores[blogID] += idf * weighted
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for blogID, score := range scores {
+		doc := idx.docs[blogID]
+		if !idx.matchesFilters(blogID, doc, q) {
+			continue
+		}
+		// Normalise by length so long posts don't always win
+		length := len(doc.fields[fieldTitle]) + len(doc.fields[fieldTags]) + len(doc.fields[fieldContent])
+		hits = append(hits, Hit{BlogID: blogID, Score: score / math.Sqrt(float64(length+1))})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		a, b := idx.docs[hits[i].BlogID], idx.docs[hits[j].BlogID]
+		if !a.createdAt.Equal(b.createdAt) {
+			return a.createdAt.After(b.createdAt)
+		}
+		return hits[i].BlogID < hits[j].BlogID
+	})
+
+	count := len(hits)
+	if offset >= count {
+		return []Hit{}, count
+	}
+	end := min(offset+limit, count)
+	return hits[offset:end], count
+}
+
+func (idx *MemoryIndex) matchesFilters(blogID string, doc *document, q Query) bool {
+	for _, term := range q.Excluded {
+		if _, ok := idx.postings[term][blogID]; ok {
+			return false
+		}
+	}
+	for _, phrase := range q.Phrases {
+		if !doc.containsPhrase(phrase) {
+			return false
+		}
+	}
+	for _, phrase := range q.ExcludedPhrases {
+		if doc.containsPhrase(phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+func (doc *document) containsPhrase(phrase []string) bool {
+	for _, tokens := range doc.fields {
+	next:
+		for i := 0; i+len(phrase) <= len(tokens); i++ {
+			for j, word := range phrase {
+				if tokens[i+j] != word {
+					continue next
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
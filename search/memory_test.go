@@ -0,0 +1,63 @@
+package search_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/search"
+)
+
+func TestMemoryIndexSearch(t *testing.T) {
+	idx := search.NewMemoryIndex()
+	blogs := []models.Blog{
+		{BlogID: "hello", Title: "Hello world in Go", Content: "Printing hello world."},
+		{BlogID: "worlds", Title: "Go worlds", Content: "Hello, other world of Go."},
+		{BlogID: "java", Title: "Java streams", Content: "Hello world in Java.", Tags: []string{"java"}},
+		{BlogID: "errors", Title: "Go errors", Content: "Error handling done right.", Tags: []string{"go"}},
+	}
+	for i := range blogs {
+		blogs[i].CreatedAt = time.Date(2024, 1, i+1, 0, 0, 0, 0, time.UTC)
+		idx.Add(&blogs[i])
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"go", []string{"errors", "hello", "worlds"}},
+		{`"hello world"`, []string{"hello", "java"}},
+		{`go "hello world"`, []string{"hello", "java"}},
+		{"go -java", []string{"errors", "hello", "worlds"}},
+		{"hello -java", []string{"hello", "worlds"}},
+		{`go -"hello world"`, []string{"errors", "worlds"}},
+		{`hello -"Hello, World"`, []string{"worlds"}},
+		{`go -"error handling" -"hello world"`, []string{"worlds"}},
+		{"rust", []string{}},
+	}
+	for _, tt := range tests {
+		hits, total := idx.Search(search.ParseQuery(tt.query), 0, 10)
+		got := make([]string, len(hits))
+		for i, hit := range hits {
+			got[i] = hit.BlogID
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) || total != len(tt.want) {
+			t.Errorf("%q found %v (%d in total), want %v", tt.query, got, total, tt.want)
+		}
+	}
+}
+
+func TestMemoryIndexRemove(t *testing.T) {
+	idx := search.NewMemoryIndex()
+	idx.Add(&models.Blog{BlogID: "b1", Title: "Go"})
+	idx.Add(&models.Blog{BlogID: "b1", Title: "Rust"})
+	if hits, _ := idx.Search(search.ParseQuery("go"), 0, 10); len(hits) != 0 {
+		t.Errorf("a replaced blog still matches its old title: %v", hits)
+	}
+	idx.Remove("b1")
+	if hits, _ := idx.Search(search.ParseQuery("rust"), 0, 10); len(hits) != 0 {
+		t.Errorf("a removed blog still matches: %v", hits)
+	}
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Words ignored by both the index and queries
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "with": true,
+}
+
+var queryPattern = regexp.MustCompile(`-?"[^"]*"|\S+`)
+
+type Query struct {
+	Raw             string
+	Terms           []string
+	Phrases         [][]string
+	Excluded        []string
+	ExcludedPhrases [][]string
+}
+
+// ParseQuery splits a search string into terms, "quoted phrases",
+// -excluded terms and -"excluded phrases", following MongoDB $text
+// semantics.
+func ParseQuery(raw string) Query {
+	q := Query{Raw: strings.TrimSpace(raw)}
+	for _, part := range queryPattern.FindAllString(q.Raw, -1) {
+		excluded := strings.HasPrefix(part, "-")
+		part = strings.TrimPrefix(part, "-")
+		if strings.HasPrefix(part, `"`) {
+			tokens := Tokenize(strings.Trim(part, `"`))
+			if len(tokens) == 0 {
+				continue
+			}
+			if excluded {
+				q.ExcludedPhrases = append(q.ExcludedPhrases, tokens)
+			} else {
+				q.Phrases = append(q.Phrases, tokens)
+			}
+			continue
+		}
+		for _, token := range Tokenize(part) {
+			if excluded {
+				q.Excluded = append(q.Excluded, token)
+			} else {
+				q.Terms = append(q.Terms, token)
+			}
+		}
+	}
+	return q
+}
+
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// Normalized is a canonical form of the query, used for cache keys and as
+// the MongoDB $text search string.
+func (q Query) Normalized() string {
+	parts := append([]string{}, q.Terms...)
+	for _, phrase := range q.Phrases {
+		parts = append(parts, `"`+strings.Join(phrase, " ")+`"`)
+	}
+	for _, term := range q.Excluded {
+		parts = append(parts, "-"+term)
+	}
+	for _, phrase := range q.ExcludedPhrases {
+		parts = append(parts, `-"`+strings.Join(phrase, " ")+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Tokenize lowercases text and splits it into words, dropping stop words.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	tokens := fields[:0]
+	for _, field := range fields {
+		if !stopWords[field] {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,65 @@
+package search_test
+
+import (
+	"reflect"
+	"testing"
+
+	"inkinkink111/go-blog-management/search"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		raw             string
+		terms           []string
+		phrases         [][]string
+		excluded        []string
+		excludedPhrases [][]string
+	}{
+		{raw: "Go Fiber", terms: []string{"go", "fiber"}},
+		{raw: "  the art of go  ", terms: []string{"art", "go"}},
+		{raw: `"error handling" go`, terms: []string{"go"}, phrases: [][]string{{"error", "handling"}}},
+		{raw: `"the end of it"`, phrases: [][]string{{"end"}}},
+		{raw: "go -java", terms: []string{"go"}, excluded: []string{"java"}},
+		{raw: `go -"java spring"`, terms: []string{"go"}, excludedPhrases: [][]string{{"java", "spring"}}},
+		{raw: `-"the" go`, terms: []string{"go"}},
+		{raw: `"" "the"`},
+		{raw: "node.js", terms: []string{"node", "js"}},
+		{raw: "Café 東京", terms: []string{"café", "東京"}},
+	}
+	for _, tt := range tests {
+		q := search.ParseQuery(tt.raw)
+		if !reflect.DeepEqual(q.Terms, tt.terms) {
+			t.Errorf("%q: terms are %q, want %q", tt.raw, q.Terms, tt.terms)
+		}
+		if !reflect.DeepEqual(q.Phrases, tt.phrases) {
+			t.Errorf("%q: phrases are %q, want %q", tt.raw, q.Phrases, tt.phrases)
+		}
+		if !reflect.DeepEqual(q.Excluded, tt.excluded) {
+			t.Errorf("%q: excluded terms are %q, want %q", tt.raw, q.Excluded, tt.excluded)
+		}
+		if !reflect.DeepEqual(q.ExcludedPhrases, tt.excludedPhrases) {
+			t.Errorf("%q: excluded phrases are %q, want %q", tt.raw, q.ExcludedPhrases, tt.excludedPhrases)
+		}
+		if wantEmpty := len(tt.terms) == 0 && len(tt.phrases) == 0; q.IsEmpty() != wantEmpty {
+			t.Errorf("%q: IsEmpty is %v, want %v", tt.raw, q.IsEmpty(), wantEmpty)
+		}
+	}
+}
+
+func TestQueryNormalized(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"Go", "go"},
+		{`  GO   "Error  Handling" -Java `, `go "error handling" -java`},
+		{`-java "error handling" go`, `go "error handling" -java`},
+		{`go -"Hello,  World"`, `go -"hello world"`},
+		{"the", ""},
+	}
+	for _, tt := range tests {
+		if got := search.ParseQuery(tt.raw).Normalized(); got != tt.want {
+			t.Errorf("%q normalizes to %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package search
+
+import (
+	"os"
+
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+)
+
+// Memory is the in-process index, nil unless SEARCH_BACKEND=memory.
+var Memory *MemoryIndex
+
+func UseMemoryIndex() bool {
+	return os.Getenv("SEARCH_BACKEND") == "memory"
+}
+
+// LoadMemoryIndex builds the in-process index from every stored blog.
+func LoadMemoryIndex() error {
+	blogs, err := repositories.NewBlogRepository().ListAllBlogs()
+	if err != nil {
+		return err
+	}
+	idx := NewMemoryIndex()
+	for i := range blogs {
+		if blogs[i].Published() {
+			idx.Add(&blogs[i])
+		}
+	}
+	Memory = idx
+	return nil
+}
+
+// IndexBlog keeps the in-process index in sync after a write. The MongoDB
+// text index needs no help.
+func IndexBlog(blog *models.Blog) {
+	if Memory == nil {
+		return
+	}
+	// Drafts are not searchable
+	if !blog.Published() {
+		Memory.Remove(blog.BlogID)
+		return
+	}
+	Memory.Add(blog)
+}
+
+func RemoveBlog(blogID string) {
+	if Memory != nil {
+		Memory.Remove(blogID)
+	}
+}
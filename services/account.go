@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/mail"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/search"
+	"inkinkink111/go-blog-management/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// How long an email verification link stays valid
+const emailVerificationTTL = 24 * time.Hour
+
+// @Summary Change my password
+// @Description Requires the current password. Every other session is logged out, use the returned token from now on.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param password body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} object{message=string,data=object{token=string}}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/password [post]
+// @Router /api/v2/me/password [put]
+func ChangePassword(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	// Extract and validate body
+	body := &models.ChangePasswordRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	if !utils.ComparePassword(user.Password, body.CurrentPassword) {
+		return apperror.Unauthorized(apperror.CodeInvalidCredentials, "Current password is incorrect.")
+	}
+	// Store the new hash and log out every session
+	hashedPassword, err := utils.HashPassword(body.NewPassword)
+	if err != nil {
+		return err
+	}
+	userRepo := repositories.NewUserRepository()
+	if err := userRepo.UpdateUserFields(user.UserId, bson.M{"password": hashedPassword}); err != nil {
+		return err
+	}
+	if err := userRepo.RevokeTokens(user.UserId, time.Now()); err != nil {
+		return err
+	}
+	// Keep the current session with a fresh token
+	token, err := utils.GenerateToken(user.Email, user.UserId)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Password changed successfully.",
+		Data: map[string]string{
+			"token": token,
+		},
+	})
+}
+
+// @Summary Change my email
+// @Description Requires the password. The new address only replaces the current one once verified with the emailed token.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param email body models.ChangeEmailRequest true "New email and password"
+// @Success 202 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Failure 503 {object} apperror.Problem
+// @Router /api/v1/me/email [post]
+// @Router /api/v2/me/email [put]
+func ChangeEmail(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	// Extract and validate body
+	body := &models.ChangeEmailRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	if !utils.ComparePassword(user.Password, body.Password) {
+		return apperror.Unauthorized(apperror.CodeInvalidCredentials, "Password is incorrect.")
+	}
+	// The change can't be confirmed without sending the token
+	if !mail.Configured() {
+		return apperror.New(fiber.StatusServiceUnavailable, apperror.CodeMailUnavailable, "Email changes are unavailable, no mail provider is configured.")
+	}
+	userRepo := repositories.NewUserRepository()
+	existingUser, err := userRepo.GetUserByEmail(body.Email)
+	if err != nil {
+		return err
+	}
+	if existingUser != nil {
+		return apperror.Conflict(apperror.CodeEmailTaken, "Email is already in use.")
+	}
+	// Only a hash of the token is stored
+	token := utils.GenerateSecret()
+	err = userRepo.UpdateUserFields(user.UserId, bson.M{
+		"pending_email":              body.Email,
+		"email_verification_hash":    utils.HashSecret(token),
+		"email_verification_expires": time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.SiteURL(), token)
+	message := fmt.Sprintf("Confirm your new email address within 24 hours: %s\n\nVerification token: %s", link, token)
+	if err := mail.Default.Send(body.Email, "Confirm your new email address", message); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusAccepted).JSON(models.ResponseMsg{
+		Message: "Check your new email address to confirm the change.",
+	})
+}
+
+// @Summary Verify a new email
+// @Description Confirms an email change with the emailed token. No login is needed, the token proves ownership.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param token body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/email/verify [post]
+// @Router /api/v2/email-verifications [post]
+func VerifyEmail(c *fiber.Ctx) error {
+	// Extract and validate body
+	body := &models.VerifyEmailRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	userRepo := repositories.NewUserRepository()
+	user, err := userRepo.GetUserByVerificationHash(utils.HashSecret(body.Token))
+	if err != nil {
+		return err
+	}
+	if user == nil || user.PendingEmail == "" || time.Now().After(user.EmailVerificationExpires) {
+		return apperror.BadRequest(apperror.CodeInvalidToken, "Verification token is invalid or expired.")
+	}
+	// The address may have been taken since the change was requested
+	existingUser, err := userRepo.GetUserByEmail(user.PendingEmail)
+	if err != nil {
+		return err
+	}
+	if existingUser != nil {
+		return apperror.Conflict(apperror.CodeEmailTaken, "Email is already in use.")
+	}
+	err = userRepo.UpdateUserFields(user.UserId, bson.M{
+		"email":                      user.PendingEmail,
+		"email_verified":             true,
+		"pending_email":              "",
+		"email_verification_hash":    "",
+		"email_verification_expires": time.Time{},
+	})
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: "Email changed successfully.",
+	})
+}
+
+// @Summary Delete my account
+// @Description Requires the password. Blogs are deleted, anonymized or transferred to another user, defaulting to ACCOUNT_BLOG_POLICY. The account is logged out and hidden at once. If removing its data fails, 202 is returned and the deletion is retried in the background.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param account body models.DeleteAccountRequest true "Password and blog policy"
+// @Success 200 {object} models.ResponseMsg
+// @Success 202 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me [delete]
+// @Router /api/v2/me [delete]
+func DeleteAccount(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	// Extract and validate body
+	body := &models.DeleteAccountRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	if !utils.ComparePassword(user.Password, body.Password) {
+		return apperror.Unauthorized(apperror.CodeInvalidCredentials, "Password is incorrect.")
+	}
+	deletion := models.AccountDeletion{Policy: body.Blogs, RequestedAt: time.Now()}
+	if deletion.Policy == "" {
+		deletion.Policy = config.AccountBlogPolicy()
+	}
+	userRepo := repositories.NewUserRepository()
+	if deletion.Policy == models.BlogPolicyTransfer {
+		var recipient *models.User
+		if body.TransferTo != "" && body.TransferTo != user.UserId {
+			recipient, err = userRepo.GetUserByUserID(body.TransferTo)
+			if err != nil {
+				return err
+			}
+		}
+		if recipient == nil || recipient.Deletion != nil {
+			return apperror.Validation("Invalid body.", apperror.Invalid("transfer_to", "transfer_to must be the id of another user"))
+		}
+		deletion.TransferTo = recipient.UserId
+	}
+	// Nothing is touched before the account is logged out and hidden, so
+	// a failure below leaves a deletion to finish, not a half-deleted
+	// account still in use
+	if err := userRepo.MarkDeleting(user.UserId, deletion); err != nil {
+		return err
+	}
+	user.Deletion = &deletion
+	if err := finishAccountDeletion(user); err != nil {
+		log.Printf("Failed to delete account %s, retrying later: %v", user.UserId, err)
+		return c.Status(fiber.StatusAccepted).JSON(models.ResponseMsg{
+			Message: "Account deletion will finish shortly.",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: "Account deleted successfully.",
+	})
+}
+
+// finishAccountDeletion removes or hands over the data of an account being
+// deleted, then the account itself. Every step only acts on what is left,
+// so a failed deletion is finished by running it again.
+func finishAccountDeletion(user *models.User) error {
+	userRepo := repositories.NewUserRepository()
+	blogRepo := repositories.NewBlogRepository()
+	// Handle the blogs first so none is left without an author
+	policy, recipientID := user.Deletion.Policy, user.Deletion.TransferTo
+	if policy == models.BlogPolicyTransfer {
+		// The recipient may have left since
+		recipient, err := userRepo.GetUserByUserID(recipientID)
+		if err != nil {
+			return err
+		}
+		if recipient == nil || recipient.Deletion != nil {
+			policy = models.BlogPolicyAnonymize
+		}
+	}
+	var blogIDs []string
+	var err error
+	switch policy {
+	case models.BlogPolicyDelete:
+		blogIDs, err = blogRepo.DeleteBlogsByAuthor(user.UserId)
+	case models.BlogPolicyTransfer:
+		blogIDs, err = blogRepo.ReassignBlogs(user.UserId, recipientID)
+	default:
+		blogIDs, err = blogRepo.ReassignBlogs(user.UserId, models.DeletedUserID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(blogIDs) > 0 {
+		cacheKeys := make([]string, len(blogIDs))
+		for i, blogID := range blogIDs {
+			cacheKeys[i] = fmt.Sprintf("blog:post:%s", blogID)
+		}
+		db.RedisClient.Del(context.Background(), cacheKeys...)
+		if policy == models.BlogPolicyDelete {
+			for _, blogID := range blogIDs {
+				search.RemoveBlog(blogID)
+			}
+			purgeBlogs(blogIDs)
+		}
+	}
+	// Comments on other blogs are anonymized
+	commentRepo := repositories.NewCommentRepository()
+	commentedIDs, err := commentRepo.ListCommentedBlogIDs(user.UserId)
+	if err != nil {
+		return err
+	}
+	if err := commentRepo.ReassignComments(user.UserId, models.DeletedUserID); err != nil {
+		return err
+	}
+	for _, blogID := range commentedIDs {
+		invalidateCommentCaches(blogID, false)
+	}
+	// Bookmarks, lists and reactions are personal, they go with the account
+	if err := repositories.NewBookmarkRepository().DeleteBookmarksByUser(user.UserId); err != nil {
+		return err
+	}
+	if err := repositories.NewReadingListRepository().DeleteListsByUser(user.UserId); err != nil {
+		return err
+	}
+	// Follows go both ways, followers' timelines still hold the user's blogs
+	followRepo := repositories.NewFollowRepository()
+	followerIDs, err := followRepo.ListFollowerIDs(user.UserId, nil)
+	if err != nil {
+		return err
+	}
+	dropTimelines(append(followerIDs, user.UserId)...)
+	if err := followRepo.DeleteFollowsByUser(user.UserId); err != nil {
+		return err
+	}
+	reactions, err := repositories.NewReactionRepository().DeleteReactionsByUser(user.UserId)
+	if err != nil {
+		return err
+	}
+	if len(reactions) > 0 {
+		reactedIDs := make([]string, len(reactions))
+		for i, reaction := range reactions {
+			reactedIDs[i] = reaction.BlogID
+		}
+		if err := recountReactions(reactedIDs); err != nil {
+			return err
+		}
+	}
+	if err := userRepo.DeleteUser(user.UserId); err != nil {
+		return err
+	}
+	// Lists, tags and the author page changed whatever happened to the blogs
+	invalidateListCaches()
+	rebuildSitemap()
+	return nil
+}
+
+// StartAccountDeletions finishes, every interval until the process exits,
+// the account deletions that failed or were cut short by a restart.
+func StartAccountDeletions(interval time.Duration) {
+	go func() {
+		for ; ; time.Sleep(interval) {
+			users, err := repositories.NewUserRepository().ListDeletingUsers()
+			if err != nil {
+				log.Printf("Failed to list accounts being deleted: %v", err)
+				continue
+			}
+			for i := range users {
+				if err := finishAccountDeletion(&users[i]); err != nil {
+					log.Printf("Failed to delete account %s: %v", users[i].UserId, err)
+				}
+			}
+		}
+	}()
+}
@@ -3,54 +3,100 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
 	"inkinkink111/go-blog-management/db"
 	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/related"
 	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/search"
 	"inkinkink111/go-blog-management/utils"
-	"strconv"
+	"log"
+	"slices"
 	"strings"
 	"time"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
+// Number of ids tried before CreateBlog gives up
+const maxIDAttempts = 3
+
 // @Summary Get all blogs
-// @Description Get paginated list of blogs with optional tag filtering
+// @Description Get a sorted list of blog summaries with optional tag filtering. Page with either page numbers or the returned next_cursor/prev_cursor.
 // @Tags blogs
 // @Accept json
 // @Produce json
-// @Param page query string false "Page number" default(1)
-// @Param limit query string false "Items per page" default(10)
-// @Param tags query string false "Comma-separated tags"
+// @Param page query string false "Page number, ignored when a cursor is given" default(1)
+// @Param limit query string false "Items per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Param sort query string false "Sort order, popularity ranks by counted views" Enums(newest, oldest, updated, title, popularity, likes) default(newest)
+// @Param cursor query string false "next_cursor or prev_cursor from a previous page"
+// @Param tags query string false "Comma-separated tags, prefix a tag with - to exclude it"
+// @Param tag_mode query string false "Match any or all of the tags" Enums(any, all) default(any)
+// @Param include_content query bool false "Return full blogs instead of summaries" default(false)
+// @Param embed query string false "Embed the author summary of each blog" Enums(author)
 // @Success 200 {object} models.GetAllBlogRequest
-// @Failure 500 {object} models.ResponseError
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/v1/all_blogs [get]
+// @Router /api/v2/blogs [get]
 func GetAllBlogs(c *fiber.Ctx) error {
 	// Get query params
-	page := c.Query("page", "1")
-	limit := c.Query("limit", "10")
+	var errs []apperror.FieldError
+	pageInt, limitInt := parsePagination(c, &errs)
 	tags := c.Query("tags", "")
-	// Convert page and limit to int
-	pageInt, err := strconv.Atoi(page)
-	if err != nil {
-		pageInt = 1
+	tagMode := c.Query("tag_mode", models.TagModeAny)
+	sort := c.Query("sort", models.SortNewest)
+	cursorToken := c.Query("cursor", "")
+	withContent := c.QueryBool("include_content", false)
+	embedAuthor := parseEmbed(c, &errs)
+	// Validate
+	if tagMode != models.TagModeAny && tagMode != models.TagModeAll {
+		errs = append(errs, apperror.Invalid("tag_mode", "tag_mode must be one of any, all"))
 	}
-	limitInt, err := strconv.Atoi(limit)
-	if err != nil {
-		limitInt = 10
+	if !slices.Contains(models.BlogSorts, sort) {
+		errs = append(errs, apperror.Invalid("sort", "sort must be one of "+strings.Join(models.BlogSorts, ", ")))
+	}
+	var cursor *models.ListCursor
+	if cursorToken != "" {
+		var err error
+		cursor, err = utils.DecodeCursor(cursorToken)
+		if err != nil {
+			errs = append(errs, apperror.Invalid("cursor", "cursor is invalid"))
+		} else if cursor.Sort != sort {
+			errs = append(errs, apperror.Invalid("cursor", "cursor was issued for a different sort"))
+		}
+	}
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
 	}
-	// Convert tags to slice
-	var tagSlice []string
+	query := models.BlogListQuery{
+		Page:        pageInt,
+		Limit:       limitInt,
+		AuthorID:    c.Params("user_id"),
+		TagMode:     tagMode,
+		Sort:        sort,
+		Cursor:      cursor,
+		WithContent: withContent,
+		EmbedAuthor: embedAuthor,
+	}
+	// Split tags into included and excluded, resolving aliases
 	if tags != "" {
-		tagSlice = strings.Split(tags, ",")
-		// Trim spaces from each tag
-		for i, tag := range tagSlice {
-			tagSlice[i] = strings.TrimSpace(tag)
+		var err error
+		query.Tags, query.ExcludedTags = utils.ParseTagFilter(tags)
+		if query.Tags, err = canonicalizeTags(query.Tags); err == nil {
+			query.ExcludedTags, err = canonicalizeTags(query.ExcludedTags)
+		}
+		if err != nil {
+			return err
 		}
 	}
 	// Check for cache hit
-	cacheKey := utils.GenerateCacheKey(page, limit, tagSlice)
+	cacheKey := utils.GenerateCacheKey(query)
 	cachedResult := db.RedisClient.Get(context.Background(), cacheKey)
 	if (cachedResult.Err() == nil) && (cachedResult.Val() != "") {
 		var cachedResponse map[string]any
@@ -64,21 +110,43 @@ func GetAllBlogs(c *fiber.Ctx) error {
 	}
 	// Cache miss - get from database
 	blogRepo := repositories.NewBlogRepository()
-	blogs, totalCount, err := blogRepo.GetAllBlogs(pageInt, limitInt, tagSlice)
+	blogs, totalCount, hasMore, err := blogRepo.GetAllBlogs(query)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to get all blogs.",
-			Error:   err.Error(),
-		})
+		return err
+	}
+	if embedAuthor {
+		page := make([]*models.Blog, len(blogs))
+		for i := range blogs {
+			page[i] = &blogs[i]
+		}
+		if err := embedAuthors(page...); err != nil {
+			return err
+		}
 	}
 	// Prep resp data
+	var blogList any = blogs
+	if !withContent {
+		summaries := make([]models.BlogSummary, len(blogs))
+		for i := range blogs {
+			summaries[i] = blogs[i].Summary()
+		}
+		blogList = summaries
+	}
+	nextCursor, prevCursor := pageCursors(query, blogs, totalCount, hasMore)
 	respData := map[string]any{
-		"blogs":       blogs,
+		"blogs":       blogList,
 		"page":        pageInt,
 		"limit":       limitInt,
+		"sort":        sort,
+		"next_cursor": nextCursor,
+		"prev_cursor": prevCursor,
 		"total_pages": (totalCount + int64(limitInt) - 1) / int64(limitInt),
 		"total_item":  totalCount,
 	}
+	if cursor != nil {
+		// Page numbers don't apply to cursor pages
+		respData["page"] = nil
+	}
 	// Set cache
 	cacheValue, _ := json.Marshal(respData)
 	db.RedisClient.Set(context.Background(), cacheKey, cacheValue, 7*24*time.Hour)
@@ -89,22 +157,96 @@ func GetAllBlogs(c *fiber.Ctx) error {
 	})
 }
 
+// @Summary Get a user's blogs
+// @Description Same as listing all blogs, restricted to one author
+// @Tags blogs
+// @Accept json
+// @Produce json
+// @Param user_id path string true "Author user id"
+// @Param page query string false "Page number, ignored when a cursor is given" default(1)
+// @Param limit query string false "Items per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Param sort query string false "Sort order, popularity ranks by counted views" Enums(newest, oldest, updated, title, popularity, likes) default(newest)
+// @Param cursor query string false "next_cursor or prev_cursor from a previous page"
+// @Param tags query string false "Comma-separated tags, prefix a tag with - to exclude it"
+// @Param tag_mode query string false "Match any or all of the tags" Enums(any, all) default(any)
+// @Param include_content query bool false "Return full blogs instead of summaries" default(false)
+// @Param embed query string false "Embed the author summary of each blog" Enums(author)
+// @Success 200 {object} models.GetAllBlogRequest
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Router /api/v1/users/{user_id}/blogs [get]
+// @Router /api/v2/users/{user_id}/blogs [get]
+func GetUserBlogs(c *fiber.Ctx) error {
+	user, err := repositories.NewUserRepository().GetUserByUserID(c.Params("user_id"))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return apperror.NotFound(apperror.CodeUserNotFound, "User not found.")
+	}
+	return GetAllBlogs(c)
+}
+
+// pageCursors returns the cursors of the pages after and before blogs,
+// empty when there is no such page.
+func pageCursors(query models.BlogListQuery, blogs []models.Blog, totalCount int64, hasMore bool) (string, string) {
+	if len(blogs) == 0 {
+		return "", ""
+	}
+	var hasNext, hasPrev bool
+	switch {
+	case query.Cursor == nil:
+		hasNext = int64(query.Page*query.Limit) < totalCount
+		hasPrev = query.Page > 1
+	case query.Cursor.Before:
+		hasNext = true
+		hasPrev = hasMore
+	default:
+		hasNext = hasMore
+		hasPrev = true
+	}
+	var next, prev string
+	if hasNext {
+		next = utils.EncodeCursor(models.CursorFor(&blogs[len(blogs)-1], query.Sort, false))
+	}
+	if hasPrev {
+		prev = utils.EncodeCursor(models.CursorFor(&blogs[0], query.Sort, true))
+	}
+	return next, prev
+}
+
 // @Summary Get blog by id
+// @Description Reaction counts are live. Logged-in readers also get my_reactions, the flags of their own reactions. Drafts are only found by their author.
 // @Tags blogs
 // @Accept json
 // @Produce json
+// @Param blog_id path string true "Blog id"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param embed query string false "Embed the author summary" Enums(author)
 // @Success 200 {object} models.GetBlogByIDResponse
-// @Failure 500 {object} models.ResponseError
-// @Router /api/v1/blogs/:blog_id [get]
+// @Success 304 "Not modified"
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/blog/{blog_id} [get]
+// @Router /api/v2/blogs/{blog_id} [get]
 func GetBlogByID(c *fiber.Ctx) error {
 	// Get blog id
 	blogID := c.Params("blog_id")
 	// Validate
 	if blogID == "" {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseMsg{
-			Message: "Missing blog id.",
-		})
+		return apperror.Validation("Missing blog id.", apperror.Required("blog_id"))
+	}
+	blogID, ok := utils.NormalizeID(blogID)
+	if !ok {
+		return apperror.BadRequest(apperror.CodeInvalidBlogID, "Invalid blog id.")
 	}
+	var errs []apperror.FieldError
+	embedAuthor := parseEmbed(c, &errs)
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	viewerID, _ := c.Locals("userId").(string)
 	// Check cache
 	cacheKey := fmt.Sprintf("blog:post:%s", blogID)
 	cachedResult := db.RedisClient.Get(context.Background(), cacheKey)
@@ -112,104 +254,165 @@ func GetBlogByID(c *fiber.Ctx) error {
 		var blog models.Blog
 		err := json.Unmarshal([]byte(cachedResult.Val()), &blog)
 		if err != nil {
-			return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-				Message: "Failed to get blog.",
-				Error:   err.Error(),
-			})
+			return err
+		}
+		// Entries cached before versioning are refreshed from the database
+		if blog.Version > 0 {
+			if !canView(&blog, viewerID) {
+				return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
+			}
+			recordView(c, &blog)
+			return sendBlog(c, &blog, embedAuthor)
 		}
-		println("Get blog from cache")
-		return c.Status(fiber.StatusOK).JSON(models.ResponseData{
-			Message: "Get blog successfully.",
-			Data:    blog,
-		})
 	}
 	// No cache hit, get blog from database
 	blogRepo := repositories.NewBlogRepository()
 	blog, err := blogRepo.GetBlogByID(blogID)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to get blog.",
-			Error:   err.Error(),
-		})
+		return err
 	}
-	if blog == nil {
-		return c.Status(fiber.ErrNotFound.Code).JSON(models.ResponseMsg{
-			Message: "Blog not found.",
-		})
+	if blog == nil || !canView(blog, viewerID) {
+		return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
 	}
-	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+	recordView(c, blog)
+	// Migrate stores the html of blogs written before markdown rendering,
+	// render any that still lack it
+	if blog.ContentHTML == "" && blog.Content != "" {
+		blog.ContentHTML, blog.TOC, err = utils.RenderMarkdown(blog.Content)
+		if err != nil {
+			return err
+		}
+		blog.Excerpt, blog.WordCount, blog.ReadingTime = utils.SummarizeContent(blog.ContentHTML)
+	}
+	// Cache the blog with its rendered content
+	blogJSON, _ := json.Marshal(blog)
+	db.RedisClient.Set(context.Background(), cacheKey, blogJSON, 7*24*time.Hour)
+	return sendBlog(c, blog, embedAuthor)
+}
+
+// sendBlog sends a blog with its live reactions and, when asked, its
+// author. The ETag covers the whole body so a 304 is only sent when
+// nothing the reader sees changed. Logged-in readers see their own
+// reactions, so shared caches must tell them apart.
+func sendBlog(c *fiber.Ctx, blog *models.Blog, embedAuthor bool) error {
+	if embedAuthor {
+		if err := embedAuthors(blog); err != nil {
+			return err
+		}
+	}
+	if err := withReactions(c, blog); err != nil {
+		return err
+	}
+	body, err := json.Marshal(models.ResponseData{
 		Message: "Get blog successfully.",
 		Data:    blog,
 	})
+	if err != nil {
+		return err
+	}
+	c.Vary(fiber.HeaderAuthorization)
+	etag := utils.BlogBodyETag(blog.Version, body)
+	c.Set(fiber.HeaderETag, etag)
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" && utils.MatchETag(ifNoneMatch, etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(fiber.StatusOK).Send(body)
 }
 
 // @Summary Create a new blog post
-// @Description Create a new blog post with title, content, and tags
+// @Description Create a new blog post with title, Markdown content, and tags. The server returns sanitized HTML and a table of contents rendered from the Markdown.
 // @Tags blogs
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param blog body models.CreateBlogRequest true "Blog data"
-// @Success 200 {object} models.CreateBlogSuccess
-// @Failure 400 {object} models.CreateBlogError
-// @Failure 500 {object} models.CreateBlogError
+// @Success 201 {object} models.CreateBlogSuccess "Created, v2"
+// @Success 200 {object} models.CreateBlogSuccess "Created, v1"
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/v1/create_blog [post]
+// @Router /api/v2/blogs [post]
 func CreateBlog(c *fiber.Ctx) error {
 	// Get Author ID from jwt
 	authorID := c.Locals("userId").(string)
-	// Extract body
-	body := &models.Blog{}
-	if err := c.BodyParser(body); err != nil {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseError{
-			Message: "Invalid body.",
-			Error:   err.Error(),
-		})
+	// Extract and validate body
+	req := &models.CreateBlogRequest{}
+	if err := parseBody(c, req); err != nil {
+		return err
 	}
-	// Validate
-	if (body.Title == "") || (body.Content == "") || (authorID == "") || (len(body.Tags) == 0) {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseError{
-			Message: "Invalid body.",
-			Error:   "Missing required fields.",
-		})
+	body := &models.Blog{
+		Title:             req.Title,
+		Content:           req.Content,
+		Tags:              utils.NormalizeTags(req.Tags),
+		CommentModeration: req.CommentModeration,
+		Status:            req.Status,
+	}
+	tags, err := canonicalizeTags(body.Tags)
+	if err != nil {
+		return err
+	}
+	body.Tags = tags
+	// Render markdown
+	contentHTML, toc, err := utils.RenderMarkdown(body.Content)
+	if err != nil {
+		return errInvalidContent(err)
 	}
 	// Prep data
+	body.ContentHTML = contentHTML
+	body.TOC = toc
+	body.Excerpt, body.WordCount, body.ReadingTime = utils.SummarizeContent(contentHTML)
 	body.Slug = utils.GenerateSlug(body.Title)
 	body.AuthorID = authorID
 	body.CreatedAt = time.Now()
 	body.UpdatedAt = time.Now()
-	body.BlogID = utils.GenerateID()
-	// Create blog
+	body.Version = 1
+	// Create blog, retry with a fresh id on the unlikely collision
 	blogRepo := repositories.NewBlogRepository()
-	err := blogRepo.InsertBlog(body)
+	for range maxIDAttempts {
+		body.BlogID = utils.GenerateID()
+		err = blogRepo.InsertBlog(body)
+		if !errors.Is(err, repositories.ErrDuplicateBlogID) {
+			break
+		}
+	}
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to create blog.",
-			Error:   err.Error(),
-		})
+		return err
 	}
 	// Cache the newly created blog
 	cacheKey := fmt.Sprintf("blog:post:%s", body.BlogID)
 	cleanBody := models.Blog{
-		BlogID:    body.BlogID,
-		Title:     body.Title,
-		Slug:      body.Slug,
-		AuthorID:  body.AuthorID,
-		Content:   body.Content,
-		Tags:      body.Tags,
-		CreatedAt: body.CreatedAt,
-		UpdatedAt: body.UpdatedAt,
+		BlogID:            body.BlogID,
+		Title:             body.Title,
+		Slug:              body.Slug,
+		AuthorID:          body.AuthorID,
+		Content:           body.Content,
+		ContentHTML:       body.ContentHTML,
+		TOC:               body.TOC,
+		Excerpt:           body.Excerpt,
+		WordCount:         body.WordCount,
+		ReadingTime:       body.ReadingTime,
+		Tags:              body.Tags,
+		CreatedAt:         body.CreatedAt,
+		UpdatedAt:         body.UpdatedAt,
+		Version:           body.Version,
+		CommentModeration: body.CommentModeration,
+		Status:            body.Status,
 	}
 	blogJSON, _ := json.Marshal(cleanBody)
 	// 7 days cache
 	db.RedisClient.Set(context.Background(), cacheKey, blogJSON, 7*24*time.Hour)
 	// Invalidate list caches (since we added a new blog)
-	pattern := "blog:list:*"
-	keys, _ := db.RedisClient.Keys(context.Background(), pattern).Result()
-	if len(keys) > 0 {
-		db.RedisClient.Del(context.Background(), keys...)
+	invalidateListCaches()
+	search.IndexBlog(body)
+	if body.Published() {
+		pushToTimelines(body)
+		queueRelated(body.BlogID, related.Document{})
 	}
+	updateSitemap(body, false, nil)
 
-	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+	c.Set(fiber.HeaderETag, utils.BlogETag(body.Version))
+	return created(c, "/api/v2/blogs/"+body.BlogID).JSON(models.ResponseData{
 		Message: "Blog created successfully.",
 		Data: map[string]string{
 			"blog_id": body.BlogID,
@@ -218,87 +421,256 @@ func CreateBlog(c *fiber.Ctx) error {
 }
 
 // @Summary Update a blog post
-// @Description Update a blog post with title, content, and tags
+// @Description Update a blog post with title, Markdown content, and tags
 // @Tags blogs
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param blog body models.CreateBlogRequest true "Blog data"
+// @Param blog_id path string true "Blog id"
+// @Param blog body models.UpdateBlogRequest true "Blog data"
+// @Param If-Match header string false "ETag of the version being updated, required when REQUIRE_IF_MATCH is set"
 // @Success 200 {object} models.ResponseMsg
-// @Failure 400 {object} models.ResponseError
-// @Failure 404 {object} models.ResponseMsg
-// @Failure 500 {object} models.ResponseError
-// @Router /api/v1/update_blog/:blog_id [put]
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 412 {object} apperror.Problem
+// @Failure 428 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/update_blog/{blog_id} [put]
+// @Router /api/v2/blogs/{blog_id} [put]
 func UpdateBlog(c *fiber.Ctx) error {
 	// Get author id & blog id
-	blogID := c.Params("blog_id")
-	authorID := c.Locals("userId").(string)
-	// Extract body
-	body := &models.Blog{}
-	if err := c.BodyParser(body); err != nil {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseError{
-			Message: "Invalid body.",
-			Error:   err.Error(),
-		})
+	blogID, ok := utils.NormalizeID(c.Params("blog_id"))
+	if !ok {
+		return apperror.BadRequest(apperror.CodeInvalidBlogID, "Invalid blog id.")
 	}
-	// Validate
-	if (body.Title == "") || (body.Content == "") || (authorID == "") || (len(body.Tags) == 0) {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseError{
-			Message: "Invalid body.",
-			Error:   "Missing required fields.",
-		})
+	authorID := c.Locals("userId").(string)
+	// Extract and validate body
+	body := &models.UpdateBlogRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
 	}
 	blogRepo := repositories.NewBlogRepository()
 	// Check if blog exists
 	blog, err := blogRepo.GetBlogByID(blogID)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to find blog.",
-			Error:   err.Error(),
-		})
+		return err
 	}
 	if blog == nil {
-		return c.Status(fiber.ErrNotFound.Code).JSON(models.ResponseMsg{
-			Message: "Blog not found.",
-		})
+		return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
 	}
 	// Check if blog is owned by user
 	if blog.AuthorID != authorID {
-		return c.Status(fiber.ErrForbidden.Code).JSON(models.ResponseMsg{
-			Message: "You are not authorized to update this blog.",
-		})
+		return apperror.Forbidden(apperror.CodeNotBlogAuthor, "You are not authorized to update this blog.")
+	}
+	if err := checkIfMatch(c, blog); err != nil {
+		return err
 	}
 	// Update blog
-	updatedBlog := &models.Blog{
-		BlogID:    blogID,
-		Title:     body.Title,
-		Content:   body.Content,
-		Tags:      body.Tags,
-		Slug:      utils.GenerateSlug(body.Title),
-		CreatedAt: blog.CreatedAt,
-		AuthorID:  blog.AuthorID,
-		UpdatedAt: time.Now(),
-	}
-	err = blogRepo.UpdateBlog(updatedBlog)
+	if err := saveBlogFields(blog, body.Fields()); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, utils.BlogETag(blog.Version))
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: "Blog updated successfully.",
+	})
+}
+
+// @Summary Partially update a blog post
+// @Description Apply an RFC 7396 JSON Merge Patch (application/merge-patch+json or application/json) or an RFC 6902 JSON Patch (application/json-patch+json) to the blog's title, content and tags. Only changed fields are written and the slug only changes with the title.
+// @Tags blogs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param blog_id path string true "Blog id"
+// @Param patch body models.UpdateBlogRequest true "Merge patch of the editable fields"
+// @Param If-Match header string false "ETag of the version being updated, required when REQUIRE_IF_MATCH is set"
+// @Success 200 {object} models.GetBlogByIDResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 412 {object} apperror.Problem
+// @Failure 415 {object} apperror.Problem
+// @Failure 422 {object} apperror.Problem
+// @Failure 428 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/blogs/{blog_id} [patch]
+// @Router /api/v2/blogs/{blog_id} [patch]
+func PatchBlog(c *fiber.Ctx) error {
+	// Get author id & blog id
+	blogID, ok := utils.NormalizeID(c.Params("blog_id"))
+	if !ok {
+		return apperror.BadRequest(apperror.CodeInvalidBlogID, "Invalid blog id.")
+	}
+	authorID := c.Locals("userId").(string)
+	blogRepo := repositories.NewBlogRepository()
+	// Check if blog exists
+	blog, err := blogRepo.GetBlogByID(blogID)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to update blog.",
-			Error:   err.Error(),
-		})
+		return err
+	}
+	if blog == nil {
+		return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
+	}
+	// Check if blog is owned by user
+	if blog.AuthorID != authorID {
+		return apperror.Forbidden(apperror.CodeNotBlogAuthor, "You are not authorized to update this blog.")
+	}
+	if err := checkIfMatch(c, blog); err != nil {
+		return err
+	}
+	// Apply the patch to the editable fields
+	current, _ := json.Marshal(blog.Fields())
+	var patched []byte
+	switch mediaType := strings.TrimSpace(strings.Split(c.Get(fiber.HeaderContentType), ";")[0]); mediaType {
+	case "application/json-patch+json":
+		patch, err := jsonpatch.DecodePatch(c.Body())
+		if err == nil {
+			patched, err = patch.Apply(current)
+		}
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return apperror.Conflict(apperror.CodePatchTestFailed, "Patch test failed.")
+		}
+		if err != nil {
+			return apperror.BadRequest(apperror.CodeInvalidPatch, "Invalid patch: "+err.Error())
+		}
+	case "application/merge-patch+json", fiber.MIMEApplicationJSON:
+		patched, err = jsonpatch.MergePatch(current, c.Body())
+		if err != nil {
+			return apperror.BadRequest(apperror.CodeInvalidPatch, "Invalid patch: "+err.Error())
+		}
+	default:
+		return apperror.New(fiber.StatusUnsupportedMediaType, apperror.CodeUnsupportedMediaType, "Unsupported patch format "+mediaType+".")
+	}
+	// Only the editable fields may be patched. A patch producing an
+	// invalid blog is unprocessable, not malformed.
+	fields := &models.UpdateBlogRequest{}
+	if err := decodeRequest(patched, fields); err != nil {
+		if appErr, ok := apperror.As(err); ok {
+			return appErr.WithStatus(fiber.StatusUnprocessableEntity)
+		}
+		return err
+	}
+	if err := saveBlogFields(blog, fields.Fields()); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, utils.BlogETag(blog.Version))
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Blog updated successfully.",
+		Data:    blog,
+	})
+}
+
+// errInvalidContent reports Markdown that could not be rendered
+func errInvalidContent(err error) error {
+	return &apperror.Error{
+		Status: fiber.StatusBadRequest,
+		Code:   apperror.CodeInvalidContent,
+		Detail: "Content could not be rendered.",
+		Cause:  err,
+	}
+}
+
+// errVersionConflict reports a write that lost the race against another
+func errVersionConflict(err error) error {
+	return &apperror.Error{
+		Status: fiber.StatusPreconditionFailed,
+		Code:   apperror.CodeVersionConflict,
+		Detail: "Blog was modified by someone else, reload and retry.",
+		Cause:  err,
+	}
+}
+
+// saveBlogFields writes the validated editable fields of blog that
+// changed and refreshes caches. blog is updated in place.
+func saveBlogFields(blog *models.Blog, fields models.BlogFields) error {
+	fields.Tags = utils.NormalizeTags(fields.Tags)
+	tags, err := canonicalizeTags(fields.Tags)
+	if err != nil {
+		return err
+	}
+	wasPublished, previousTags := blog.Published(), blog.Tags
+	// Drafts aren't in anyone's related blogs
+	var before related.Document
+	if wasPublished {
+		before = relatedDocument(blog)
+	}
+	// Collect changes
+	changes := bson.M{}
+	if fields.Title != blog.Title {
+		blog.Title = fields.Title
+		blog.Slug = utils.GenerateSlug(fields.Title)
+		changes["title"] = blog.Title
+		changes["slug"] = blog.Slug
+	}
+	if fields.Content != blog.Content {
+		contentHTML, toc, err := utils.RenderMarkdown(fields.Content)
+		if err != nil {
+			return errInvalidContent(err)
+		}
+		blog.Content = fields.Content
+		blog.ContentHTML = contentHTML
+		blog.TOC = toc
+		blog.Excerpt, blog.WordCount, blog.ReadingTime = utils.SummarizeContent(contentHTML)
+		changes["content"] = blog.Content
+		changes["content_html"] = blog.ContentHTML
+		changes["toc"] = blog.TOC
+		changes["excerpt"] = blog.Excerpt
+		changes["word_count"] = blog.WordCount
+		changes["reading_time"] = blog.ReadingTime
 	}
+	if !slices.Equal(tags, blog.Tags) {
+		blog.Tags = tags
+		changes["tags"] = blog.Tags
+	}
+	// Omitted by clients that predate it, which must not switch moderation
+	if fields.CommentModeration != "" && fields.CommentModeration != blog.CommentModeration {
+		blog.CommentModeration = fields.CommentModeration
+		changes["comment_moderation"] = blog.CommentModeration
+	}
+	// An update without status keeps drafts drafts
+	if fields.Status != "" && fields.Status != blog.Status {
+		blog.Status = fields.Status
+		changes["status"] = blog.Status
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	blog.UpdatedAt = time.Now()
+	changes["updated_at"] = blog.UpdatedAt
+	blogRepo := repositories.NewBlogRepository()
+	err = blogRepo.UpdateBlogFields(blog.BlogID, blog.Version, changes)
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		return errVersionConflict(err)
+	}
+	if err != nil {
+		return err
+	}
+	blog.Version++
 	// Cache the updated blog
-	cacheKey := fmt.Sprintf("blog:post:%s", blogID)
-	updatedBlogJSON, _ := json.Marshal(updatedBlog)
+	cacheKey := fmt.Sprintf("blog:post:%s", blog.BlogID)
+	updatedBlogJSON, _ := json.Marshal(blog)
 	db.RedisClient.Set(context.Background(), cacheKey, updatedBlogJSON, 24*7*time.Hour)
 	// Invalidate list caches (since we updated a blog)
-	pattern := "blog:list:*"
-	keys, _ := db.RedisClient.Keys(context.Background(), pattern).Result()
-	if len(keys) > 0 {
-		db.RedisClient.Del(context.Background(), keys...)
+	invalidateListCaches()
+	search.IndexBlog(blog)
+	if !wasPublished && blog.Published() {
+		pushToTimelines(blog)
 	}
-	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
-		Message: "Blog updated successfully.",
-	})
+	// Comments are hidden along with a blog moved back to draft
+	if _, ok := changes["status"]; ok {
+		invalidateCommentCaches(blog.BlogID, false)
+	}
+	// Related blogs are matched on title, tags and status
+	for _, field := range []string{"title", "tags", "status"} {
+		if _, ok := changes[field]; ok {
+			queueRelated(blog.BlogID, before)
+			break
+		}
+	}
+	updateSitemap(blog, wasPublished, previousTags)
+	return nil
 }
 
 // @Summary Delete a blog post
@@ -306,61 +678,117 @@ func UpdateBlog(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param blog_id path string true "Blog id"
+// @Param If-Match header string false "ETag of the version being deleted, required when REQUIRE_IF_MATCH is set"
 // @Success 200 {object} models.ResponseMsg
-// @Failure 400 {object} models.ResponseMsg
-// @Failure 403 {object} models.ResponseMsg
-// @Failure 404 {object} models.ResponseMsg
-// @Failure 500 {object} models.ResponseError
-// @Router /api/v1/delete_blog/:blog_id [delete]
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 412 {object} apperror.Problem
+// @Failure 428 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/delete_blog/{blog_id} [delete]
+// @Router /api/v2/blogs/{blog_id} [delete]
 func DeleteBlog(c *fiber.Ctx) error {
 	// Get blog id and author id
 	blogID := c.Params("blog_id")
 	authorID := c.Locals("userId").(string)
 	// Validate
 	if blogID == "" {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseMsg{
-			Message: "Invalid body missing blog id.",
-		})
+		return apperror.Validation("Missing blog id.", apperror.Required("blog_id"))
+	}
+	blogID, ok := utils.NormalizeID(blogID)
+	if !ok {
+		return apperror.BadRequest(apperror.CodeInvalidBlogID, "Invalid blog id.")
 	}
 	blogRepo := repositories.NewBlogRepository()
 	// Check if blog exists
 	blog, err := blogRepo.GetBlogByID(blogID)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to delete blog.",
-			Error:   err.Error(),
-		})
+		return err
 	}
 	if blog == nil {
-		return c.Status(fiber.ErrNotFound.Code).JSON(models.ResponseMsg{
-			Message: "Blog not found.",
-		})
+		return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
 	}
 	// Check if blog is owned by user
 	if blog.AuthorID != authorID {
-		return c.Status(fiber.ErrForbidden.Code).JSON(models.ResponseMsg{
-			Message: "You are not authorized to delete this blog.",
-		})
+		return apperror.Forbidden(apperror.CodeNotBlogAuthor, "You are not authorized to delete this blog.")
+	}
+	if err := checkIfMatch(c, blog); err != nil {
+		return err
 	}
 	// Delete blog
-	err0 := blogRepo.DeleteBlog(blogID)
-	if err0 != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to delete blog.",
-			Error:   err.Error(),
-		})
-	}
-	// Delete cache
-	cacheKey := fmt.Sprintf("blog:post:%s", blogID)
-	db.RedisClient.Del(context.Background(), cacheKey)
-	// Invalidate list caches (since we deleted a blog)
-	pattern := "blog:list:*"
-	keys, _ := db.RedisClient.Keys(context.Background(), pattern).Result()
-	if len(keys) > 0 {
-		db.RedisClient.Del(context.Background(), keys...)
+	err = blogRepo.DeleteBlog(blogID, blog.Version)
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		return errVersionConflict(err)
+	}
+	if err != nil {
+		return err
 	}
+	// The blog is gone, stop serving it before cleaning up after it
+	db.RedisClient.Del(context.Background(), fmt.Sprintf("blog:post:%s", blogID))
+	invalidateListCaches()
+	search.RemoveBlog(blogID)
+	purgeBlogs([]string{blogID})
 
 	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
 		Message: "Blog deleted successfully.",
 	})
 }
+
+// purgeBlogs removes what belonged to deleted blogs: comments, reactions,
+// saves, views and their related and sitemap entries. The blogs are already
+// gone, so failures are logged rather than failing the request.
+func purgeBlogs(blogIDs []string) {
+	if err := repositories.NewCommentRepository().DeleteCommentsByBlogs(blogIDs); err != nil {
+		log.Printf("Failed to delete comments of blogs %v: %v", blogIDs, err)
+	}
+	for _, blogID := range blogIDs {
+		invalidateCommentCaches(blogID, false)
+	}
+	if err := repositories.NewReactionRepository().DeleteReactionsByBlogs(blogIDs); err != nil {
+		log.Printf("Failed to delete reactions to blogs %v: %v", blogIDs, err)
+	}
+	dropReactionCounts(blogIDs)
+	if err := removeSavedBlogs(blogIDs); err != nil {
+		log.Printf("Failed to unsave blogs %v: %v", blogIDs, err)
+	}
+	if err := repositories.NewViewRepository().DeleteViewsByBlogs(blogIDs); err != nil {
+		log.Printf("Failed to delete views of blogs %v: %v", blogIDs, err)
+	}
+	dropViewCounts(blogIDs)
+	forgetRelated(blogIDs)
+	removeFromSitemap(blogIDs)
+}
+
+// canView reports whether a user may see a blog, drafts are only shown
+// to their author
+func canView(blog *models.Blog, userID string) bool {
+	return blog.Published() || blog.AuthorID == userID
+}
+
+// invalidateListCaches drops every cached list, search page and tag count
+func invalidateListCaches() {
+	for _, pattern := range []string{"blog:list:*", "blog:search:*", "blog:tags:*"} {
+		keys, _ := db.RedisClient.Keys(context.Background(), pattern).Result()
+		if len(keys) > 0 {
+			db.RedisClient.Del(context.Background(), keys...)
+		}
+	}
+}
+
+// checkIfMatch enforces the If-Match precondition of a write on blog
+func checkIfMatch(c *fiber.Ctx, blog *models.Blog) error {
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if ifMatch == "" {
+		if config.RequireIfMatch() {
+			return apperror.New(fiber.StatusPreconditionRequired, apperror.CodePreconditionRequired, "If-Match header is required.")
+		}
+		return nil
+	}
+	if !utils.MatchBlogVersion(ifMatch, blog.Version) {
+		c.Set(fiber.HeaderETag, utils.BlogETag(blog.Version))
+		return apperror.PreconditionFailed(apperror.CodeVersionConflict, "Blog was modified by someone else, reload and retry.")
+	}
+	return nil
+}
@@ -0,0 +1,483 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/spam"
+	"inkinkink111/go-blog-management/utils"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Roles allowed to delete anyone's comment
+var commentModerators = []string{models.RoleEditor, models.RoleAdmin}
+
+// commentPage is a page of threads as cached, without author summaries
+type commentPage struct {
+	Comments   []*models.Comment `json:"comments"`
+	Page       int               `json:"page"`
+	Limit      int               `json:"limit"`
+	TotalPages int64             `json:"total_pages"`
+	TotalItem  int64             `json:"total_item"`
+}
+
+// @Summary Get a blog's comments
+// @Description Get a page of top-level comments, oldest first, each with its nested replies. Deleted comments keep their place in the thread without content or author. Comments on a draft are only shown to its author.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param blog_id path string true "Blog id"
+// @Param page query string false "Page number" default(1)
+// @Param limit query string false "Threads per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Success 200 {object} models.GetCommentsResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/blogs/{blog_id}/comments [get]
+// @Router /api/v2/blogs/{blog_id}/comments [get]
+func GetComments(c *fiber.Ctx) error {
+	// Drafts and their comments are only shown to their author, whatever
+	// the cache holds
+	viewerID, _ := c.Locals("userId").(string)
+	blog, err := visibleBlogParam(c, viewerID)
+	if err != nil {
+		return err
+	}
+	var errs []apperror.FieldError
+	page, limit := parsePagination(c, &errs)
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	// Check for cache hit
+	var resp commentPage
+	cacheKey := utils.GenerateCommentsCacheKey(blog.BlogID, page, limit)
+	cachedResult := db.RedisClient.Get(context.Background(), cacheKey)
+	if cachedResult.Err() != nil || cachedResult.Val() == "" || json.Unmarshal([]byte(cachedResult.Val()), &resp) != nil {
+		// Cache miss - get from database
+		commentRepo := repositories.NewCommentRepository()
+		threads, totalCount, err := commentRepo.ListThreads(blog.BlogID, page, limit)
+		if err != nil {
+			return err
+		}
+		threadIDs := make([]string, len(threads))
+		for i := range threads {
+			threadIDs[i] = threads[i].CommentID
+		}
+		var replies []models.Comment
+		if len(threadIDs) > 0 {
+			if replies, err = commentRepo.ListReplies(threadIDs); err != nil {
+				return err
+			}
+		}
+		resp = commentPage{
+			Comments:   buildThreads(threads, replies),
+			Page:       page,
+			Limit:      limit,
+			TotalPages: (totalCount + int64(limit) - 1) / int64(limit),
+			TotalItem:  totalCount,
+		}
+		cacheValue, _ := json.Marshal(resp)
+		db.RedisClient.Set(context.Background(), cacheKey, cacheValue, 7*24*time.Hour)
+	}
+	if err := embedCommentAuthors(resp.Comments); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get comments successfully.",
+		Data:    resp,
+	})
+}
+
+// @Summary Comment on a blog
+// @Description Post a top-level comment, or a reply when parent_id is set. Replies nest up to 5 levels deep. On pre-moderated blogs, or when it looks like spam, the comment waits for a moderator.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param blog_id path string true "Blog id"
+// @Param comment body models.CreateCommentRequest true "Comment"
+// @Success 201 {object} object{message=string,data=models.Comment} "Created, v2"
+// @Success 200 {object} object{message=string,data=models.Comment} "Created, v1"
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/blogs/{blog_id}/comments [post]
+// @Router /api/v2/blogs/{blog_id}/comments [post]
+func CreateComment(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	blogID, err := blogIDParam(c)
+	if err != nil {
+		return err
+	}
+	// Extract and validate body
+	body := &models.CreateCommentRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	blog, err := repositories.NewBlogRepository().GetBlogByID(blogID)
+	if err != nil {
+		return err
+	}
+	if blog == nil || !canView(blog, user.UserId) {
+		return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
+	}
+	comment := &models.Comment{
+		CommentID: utils.GenerateID(),
+		BlogID:    blogID,
+		AuthorID:  user.UserId,
+		Content:   body.Content,
+		CreatedAt: time.Now(),
+	}
+	comment.ThreadID = comment.CommentID
+	// Replies join the thread of their parent
+	commentRepo := repositories.NewCommentRepository()
+	if body.ParentID != "" {
+		parentID, ok := utils.NormalizeID(body.ParentID)
+		var parent *models.Comment
+		if ok {
+			if parent, err = commentRepo.GetComment(parentID); err != nil {
+				return err
+			}
+		}
+		if parent == nil || parent.BlogID != blogID || parent.Deleted || !parent.Approved() {
+			return apperror.Validation("Invalid body.", apperror.Invalid("parent_id", "parent_id must be a comment on this blog"))
+		}
+		if parent.Depth >= models.MaxCommentDepth {
+			return apperror.BadRequest(apperror.CodeCommentTooDeep, fmt.Sprintf("Replies can only nest %d levels deep.", models.MaxCommentDepth))
+		}
+		comment.ParentID = parent.CommentID
+		comment.ThreadID = parent.ThreadID
+		comment.Depth = parent.Depth + 1
+	}
+	moderateComment(comment, blog, user, false)
+	if err := commentRepo.InsertComment(comment); err != nil {
+		return err
+	}
+	if comment.Approved() {
+		if err := repositories.NewBlogRepository().IncCommentCount(blogID, 1); err != nil {
+			return err
+		}
+		trackActivity(blog, trendingCommentWeight)
+	}
+	invalidateCommentCaches(blogID, comment.Approved())
+	view := authorView(comment, user)
+	if err := embedCommentAuthors([]*models.Comment{view}); err != nil {
+		return err
+	}
+	message := "Comment created successfully."
+	if !view.Approved() {
+		message = "Comment is awaiting moderation."
+	}
+	return created(c, "/api/v2/comments/"+view.CommentID).JSON(models.ResponseData{
+		Message: message,
+		Data:    view,
+	})
+}
+
+// @Summary Edit a comment
+// @Description Only the author can edit a comment, within COMMENT_EDIT_WINDOW of posting it. Edits are moderated again.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param comment_id path string true "Comment id"
+// @Param comment body models.UpdateCommentRequest true "New content"
+// @Success 200 {object} object{message=string,data=models.Comment}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/comments/{comment_id} [patch]
+// @Router /api/v2/comments/{comment_id} [patch]
+func UpdateComment(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	comment, err := commentParam(c)
+	if err != nil {
+		return err
+	}
+	// Extract and validate body
+	body := &models.UpdateCommentRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	if comment.AuthorID != user.UserId {
+		return apperror.Forbidden(apperror.CodeNotCommentAuthor, "You are not authorized to edit this comment.")
+	}
+	if time.Since(comment.CreatedAt) > config.CommentEditWindow() {
+		return apperror.Forbidden(apperror.CodeEditWindowClosed, "Comments can no longer be edited this long after posting.")
+	}
+	blog, err := repositories.NewBlogRepository().GetBlogByID(comment.BlogID)
+	if err != nil {
+		return err
+	}
+	if blog == nil {
+		return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
+	}
+	editedAt := time.Now()
+	wasApproved := comment.Approved()
+	comment.Content = body.Content
+	comment.EditedAt = &editedAt
+	// Rejected comments stay rejected, others are judged on the new content
+	if comment.Status != models.CommentRejected && comment.Status != models.CommentSpam {
+		moderateComment(comment, blog, user, true)
+	}
+	err = repositories.NewCommentRepository().UpdateCommentFields(comment.CommentID, bson.M{
+		"content":      comment.Content,
+		"edited_at":    editedAt,
+		"status":       comment.Status,
+		"spam_score":   comment.SpamScore,
+		"spam_reasons": comment.SpamReasons,
+	})
+	if err != nil {
+		return err
+	}
+	countChanged := wasApproved != comment.Approved()
+	if countChanged {
+		delta := 1
+		if wasApproved {
+			delta = -1
+		}
+		if err := repositories.NewBlogRepository().IncCommentCount(comment.BlogID, delta); err != nil {
+			return err
+		}
+		if delta > 0 {
+			trackActivity(blog, trendingCommentWeight)
+		}
+	}
+	invalidateCommentCaches(comment.BlogID, countChanged)
+	view := authorView(comment, user)
+	if err := embedCommentAuthors([]*models.Comment{view}); err != nil {
+		return err
+	}
+	message := "Comment updated successfully."
+	if !view.Approved() {
+		message = "Comment is awaiting moderation."
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: message,
+		Data:    view,
+	})
+}
+
+// @Summary Delete a comment
+// @Description The author, an editor or an admin can delete a comment. Its replies stay in the thread.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param comment_id path string true "Comment id"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/comments/{comment_id} [delete]
+// @Router /api/v2/comments/{comment_id} [delete]
+func DeleteComment(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	comment, err := commentParam(c)
+	if err != nil {
+		return err
+	}
+	if comment.AuthorID != user.UserId && !slices.Contains(commentModerators, user.Role) {
+		return apperror.Forbidden(apperror.CodeNotCommentAuthor, "You are not authorized to delete this comment.")
+	}
+	// Another request may have deleted it since it was read
+	deleted, err := repositories.NewCommentRepository().SoftDeleteComment(comment.CommentID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return apperror.NotFound(apperror.CodeCommentNotFound, "Comment not found.")
+	}
+	// Only approved comments are counted
+	if comment.Approved() {
+		if err := repositories.NewBlogRepository().IncCommentCount(comment.BlogID, -1); err != nil {
+			return err
+		}
+	}
+	invalidateCommentCaches(comment.BlogID, comment.Approved())
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: "Comment deleted successfully.",
+	})
+}
+
+// blogIDParam reads and normalizes the blog_id path param
+func blogIDParam(c *fiber.Ctx) (string, error) {
+	blogID := c.Params("blog_id")
+	if blogID == "" {
+		return "", apperror.Validation("Missing blog id.", apperror.Required("blog_id"))
+	}
+	blogID, ok := utils.NormalizeID(blogID)
+	if !ok {
+		return "", apperror.BadRequest(apperror.CodeInvalidBlogID, "Invalid blog id.")
+	}
+	return blogID, nil
+}
+
+// commentParam loads the live comment named by the comment_id path param
+func commentParam(c *fiber.Ctx) (*models.Comment, error) {
+	commentID, ok := utils.NormalizeID(c.Params("comment_id"))
+	if !ok {
+		return nil, apperror.BadRequest(apperror.CodeInvalidCommentID, "Invalid comment id.")
+	}
+	comment, err := repositories.NewCommentRepository().GetComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment == nil || comment.Deleted {
+		return nil, apperror.NotFound(apperror.CodeCommentNotFound, "Comment not found.")
+	}
+	return comment, nil
+}
+
+// buildThreads nests replies under their parents. Replies come sorted
+// oldest first, so parents are always seen before their children.
+func buildThreads(threads, replies []models.Comment) []*models.Comment {
+	byID := make(map[string]*models.Comment, len(threads)+len(replies))
+	roots := make([]*models.Comment, len(threads))
+	for i := range threads {
+		roots[i] = publicComment(&threads[i])
+		byID[roots[i].CommentID] = roots[i]
+	}
+	for i := range replies {
+		reply := publicComment(&replies[i])
+		byID[reply.CommentID] = reply
+		parent, ok := byID[reply.ParentID]
+		if !ok {
+			parent, ok = byID[reply.ThreadID]
+		}
+		if ok {
+			parent.Replies = append(parent.Replies, reply)
+		}
+	}
+	return roots
+}
+
+// publicComment prepares a listed comment for readers. Deleted comments
+// keep no trace of their author and spam scores stay with moderators.
+func publicComment(comment *models.Comment) *models.Comment {
+	if comment.Deleted {
+		comment.AuthorID = ""
+		comment.Content = ""
+	}
+	comment.Status = models.CommentApproved
+	comment.SpamScore = 0
+	comment.SpamReasons = nil
+	comment.Replies = []*models.Comment{}
+	return comment
+}
+
+// authorView is how a comment is shown to its author: held as spam it
+// looks pending, unless the author is shadow-banned and it looks approved.
+func authorView(comment *models.Comment, author *models.User) *models.Comment {
+	view := *comment
+	if view.Status == models.CommentSpam {
+		view.Status = models.CommentPending
+		if author.ShadowBanned {
+			view.Status = models.CommentApproved
+		}
+	}
+	view.SpamScore = 0
+	view.SpamReasons = nil
+	view.Replies = []*models.Comment{}
+	return &view
+}
+
+// moderateComment scores a new comment, or an edited one when edit is set,
+// and sets its status. If the scorer fails the comment waits for a
+// moderator.
+func moderateComment(comment *models.Comment, blog *models.Blog, author *models.User, edit bool) {
+	result, err := spam.Default.Score(spam.Comment{
+		AuthorID: comment.AuthorID,
+		BlogID:   comment.BlogID,
+		Content:  comment.Content,
+		Edit:     edit,
+	})
+	if err != nil {
+		log.Printf("spam scorer failed for comment %s: %v", comment.CommentID, err)
+		result = spam.Result{Reasons: []string{"unscored"}}
+	}
+	comment.SpamScore = result.Score
+	comment.SpamReasons = result.Reasons
+	moderation := blog.CommentModeration
+	if moderation == "" {
+		moderation = config.CommentModeration()
+	}
+	threshold := config.SpamThreshold()
+	switch {
+	case author.ShadowBanned:
+		comment.Status = models.CommentSpam
+		comment.SpamReasons = append(comment.SpamReasons, "shadow_ban")
+	case result.Score >= threshold:
+		comment.Status = models.CommentSpam
+	case err != nil || moderation == models.ModerationPre || result.Score >= threshold/2:
+		comment.Status = models.CommentPending
+	default:
+		comment.Status = models.CommentApproved
+	}
+}
+
+// embedCommentAuthors sets the author summary of every comment in the
+// threads with a single query
+func embedCommentAuthors(comments []*models.Comment) error {
+	var all []*models.Comment
+	var walk func([]*models.Comment)
+	walk = func(comments []*models.Comment) {
+		for _, comment := range comments {
+			all = append(all, comment)
+			walk(comment.Replies)
+		}
+	}
+	walk(comments)
+	authorIDs := make([]string, len(all))
+	for i, comment := range all {
+		authorIDs[i] = comment.AuthorID
+	}
+	authors, err := loadAuthors(authorIDs)
+	if err != nil {
+		return err
+	}
+	for _, comment := range all {
+		comment.Author = authors[comment.AuthorID]
+	}
+	return nil
+}
+
+// invalidateCommentCaches drops the cached comment pages of a blog. When
+// the comment count changed the blog and every list showing it are stale
+// too.
+func invalidateCommentCaches(blogID string, countChanged bool) {
+	keys, _ := db.RedisClient.Keys(context.Background(), fmt.Sprintf("blog:comments:%s:*", blogID)).Result()
+	if countChanged {
+		keys = append(keys, fmt.Sprintf("blog:post:%s", blogID))
+	}
+	if len(keys) > 0 {
+		db.RedisClient.Del(context.Background(), keys...)
+	}
+	if countChanged {
+		invalidateListCaches()
+	}
+}
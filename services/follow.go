@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/utils"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// timelineKey is the precomputed feed of a user following many authors
+// and tags: blog ids scored by their creation time in milliseconds.
+func timelineKey(userID string) string {
+	return fmt.Sprintf("feed:timeline:%s", userID)
+}
+
+// Adds a blog to a timeline only if it exists, so an expired timeline is
+// rebuilt in full rather than restarted with a single blog
+var timelinePush = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 0, -tonumber(ARGV[3]) - 1)
+end
+return 0
+`)
+
+// @Summary Follow a user
+// @Description Their new posts show up in the feed. Following a user twice changes nothing.
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User id"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/following/users/{user_id} [put]
+// @Router /api/v2/me/following/users/{user_id} [put]
+func FollowUser(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	target := c.Params("user_id")
+	if target == userID {
+		return apperror.Validation("Invalid user.", apperror.Invalid("user_id", "you can't follow yourself"))
+	}
+	user, err := repositories.NewUserRepository().GetUserByUserID(target)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return apperror.NotFound(apperror.CodeUserNotFound, "User not found.")
+	}
+	return setFollow(c, models.FollowUser, user.UserId, true, "User followed successfully.")
+}
+
+// @Summary Unfollow a user
+// @Description Unfollowing a user you don't follow changes nothing.
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User id"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/following/users/{user_id} [delete]
+// @Router /api/v2/me/following/users/{user_id} [delete]
+func UnfollowUser(c *fiber.Ctx) error {
+	return setFollow(c, models.FollowUser, c.Params("user_id"), false, "User unfollowed successfully.")
+}
+
+// @Summary Follow a tag
+// @Description New posts with the tag show up in the feed. Following an alias follows its tag.
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Tag name"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/following/tags/{name} [put]
+// @Router /api/v2/me/following/tags/{name} [put]
+func FollowTag(c *fiber.Ctx) error {
+	tag, err := canonicalTagParam(c)
+	if err != nil {
+		return err
+	}
+	return setFollow(c, models.FollowTag, tag, true, "Tag followed successfully.")
+}
+
+// @Summary Unfollow a tag
+// @Description Unfollowing a tag you don't follow changes nothing.
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Tag name"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/following/tags/{name} [delete]
+// @Router /api/v2/me/following/tags/{name} [delete]
+func UnfollowTag(c *fiber.Ctx) error {
+	tag, err := canonicalTagParam(c)
+	if err != nil {
+		return err
+	}
+	return setFollow(c, models.FollowTag, tag, false, "Tag unfollowed successfully.")
+}
+
+func setFollow(c *fiber.Ctx, followType, target string, add bool, message string) error {
+	userID := c.Locals("userId").(string)
+	followRepo := repositories.NewFollowRepository()
+	var changed bool
+	var err error
+	if add {
+		changed, err = followRepo.AddFollow(&models.Follow{
+			FollowerID: userID,
+			Type:       followType,
+			Target:     target,
+			CreatedAt:  time.Now(),
+		})
+	} else {
+		changed, err = followRepo.RemoveFollow(userID, followType, target)
+	}
+	if err != nil {
+		return err
+	}
+	// The timeline no longer matches what the user follows
+	if changed {
+		dropTimelines(userID)
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: message,
+	})
+}
+
+// canonicalTagParam reads the name path param as a canonical tag
+func canonicalTagParam(c *fiber.Ctx) (string, error) {
+	tag := utils.NormalizeTag(c.Params("name"))
+	if tag == "" {
+		return "", apperror.Validation("Invalid tag.", apperror.Required("name"))
+	}
+	tags, err := canonicalizeTags([]string{tag})
+	if err != nil {
+		return "", err
+	}
+	return tags[0], nil
+}
+
+// @Summary Get what I follow
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{message=string,data=models.Following}
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/following [get]
+// @Router /api/v2/me/following [get]
+func GetFollowing(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	follows, err := repositories.NewFollowRepository().ListFollowing(userID)
+	if err != nil {
+		return err
+	}
+	userIDs, tags := splitFollows(follows)
+	authors, err := loadAuthors(userIDs)
+	if err != nil {
+		return err
+	}
+	following := models.Following{Users: []models.AuthorSummary{}, Tags: tags}
+	for _, followedID := range userIDs {
+		if author, ok := authors[followedID]; ok {
+			following.Users = append(following.Users, *author)
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get following successfully.",
+		Data:    following,
+	})
+}
+
+// @Summary Get my feed
+// @Description Published posts by the users and with the tags I follow, newest first. Page with next_cursor. Pages can come out shorter than limit when posts were deleted or unpublished.
+// @Tags feed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param limit query string false "Items per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Param cursor query string false "next_cursor from a previous page"
+// @Param embed query string false "Embed the author summary of each blog" Enums(author)
+// @Success 200 {object} models.GetFeedResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/feed [get]
+// @Router /api/v2/feed [get]
+func GetFeed(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	// Get query params
+	var errs []apperror.FieldError
+	_, limit := parsePagination(c, &errs)
+	embedAuthor := parseEmbed(c, &errs)
+	var cursor *models.ListCursor
+	if token := c.Query("cursor", ""); token != "" {
+		var err error
+		cursor, err = utils.DecodeCursor(token)
+		if err != nil || cursor.Sort != models.SortNewest || cursor.Before {
+			errs = append(errs, apperror.Invalid("cursor", "cursor is invalid"))
+		}
+	}
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	follows, err := repositories.NewFollowRepository().ListFollowing(userID)
+	if err != nil {
+		return err
+	}
+	authorIDs, tags := splitFollows(follows)
+	// Small follow sets are cheap to query, large ones read a timeline
+	var blogs []models.Blog
+	var next *models.ListCursor
+	if len(follows) > config.FeedTimelineThreshold() {
+		blogs, next, err = timelinePage(userID, authorIDs, tags, cursor, limit)
+	} else {
+		blogs, next, err = queryFeedPage(authorIDs, tags, cursor, limit)
+	}
+	if err != nil {
+		return err
+	}
+	if embedAuthor {
+		page := make([]*models.Blog, len(blogs))
+		for i := range blogs {
+			page[i] = &blogs[i]
+		}
+		if err := embedAuthors(page...); err != nil {
+			return err
+		}
+	}
+	summaries := make([]models.BlogSummary, len(blogs))
+	for i := range blogs {
+		summaries[i] = blogs[i].Summary()
+	}
+	nextCursor := ""
+	if next != nil {
+		nextCursor = utils.EncodeCursor(*next)
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get feed successfully.",
+		Data: map[string]any{
+			"blogs":       summaries,
+			"limit":       limit,
+			"next_cursor": nextCursor,
+		},
+	})
+}
+
+// splitFollows separates followed users from followed tags
+func splitFollows(follows []models.Follow) ([]string, []string) {
+	userIDs, tags := []string{}, []string{}
+	for _, follow := range follows {
+		if follow.Type == models.FollowTag {
+			tags = append(tags, follow.Target)
+		} else {
+			userIDs = append(userIDs, follow.Target)
+		}
+	}
+	return userIDs, tags
+}
+
+// queryFeedPage reads a feed page straight from the blogs, fan-out on read
+func queryFeedPage(authorIDs, tags []string, cursor *models.ListCursor, limit int) ([]models.Blog, *models.ListCursor, error) {
+	blogs, hasMore, err := repositories.NewBlogRepository().GetFeedBlogs(authorIDs, tags, cursor, limit)
+	if err != nil || !hasMore || len(blogs) == 0 {
+		return blogs, nil, err
+	}
+	next := models.CursorFor(&blogs[len(blogs)-1], models.SortNewest, false)
+	return blogs, &next, nil
+}
+
+// timelinePage reads a feed page from the user's timeline, building it
+// first when missing. Pages past the end of a full timeline are queried.
+func timelinePage(userID string, authorIDs, tags []string, cursor *models.ListCursor, limit int) ([]models.Blog, *models.ListCursor, error) {
+	ctx := context.Background()
+	key := timelineKey(userID)
+	exists, err := db.RedisClient.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	if exists == 0 {
+		if err := buildTimeline(key, authorIDs, tags); err != nil {
+			return nil, nil, err
+		}
+	}
+	upper := "+inf"
+	var ties int64
+	if cursor != nil {
+		upper = strconv.FormatInt(cursor.Time.UnixMilli(), 10)
+		// Blogs created in the same millisecond share a score, fetch enough
+		// to skip the ones already seen
+		if ties, err = db.RedisClient.ZCount(ctx, key, upper, upper).Result(); err != nil {
+			return nil, nil, err
+		}
+	}
+	entries, err := db.RedisClient.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+		Key:     key,
+		Start:   "-inf",
+		Stop:    upper,
+		ByScore: true,
+		Rev:     true,
+		Count:   int64(limit+1) + ties,
+	}).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	positions := make([]models.ListCursor, 0, len(entries))
+	for _, entry := range entries {
+		position := models.ListCursor{
+			Sort:   models.SortNewest,
+			Time:   time.UnixMilli(int64(entry.Score)),
+			BlogID: entry.Member.(string),
+		}
+		if cursor != nil && position.Time.Equal(cursor.Time) && position.BlogID >= cursor.BlogID {
+			continue
+		}
+		positions = append(positions, position)
+	}
+	if len(positions) <= limit {
+		size, err := db.RedisClient.ZCard(ctx, key).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		if size >= int64(config.FeedTimelineSize()) {
+			return queryFeedPage(authorIDs, tags, cursor, limit)
+		}
+	}
+	var next *models.ListCursor
+	if len(positions) > limit {
+		positions = positions[:limit]
+		next = &positions[limit-1]
+	}
+	blogIDs := make([]string, len(positions))
+	for i := range positions {
+		blogIDs[i] = positions[i].BlogID
+	}
+	blogs, err := timelineBlogs(blogIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return blogs, next, nil
+}
+
+// timelineBlogs loads timeline blogs in order, skipping the ones deleted
+// or unpublished since they were added
+func timelineBlogs(blogIDs []string) ([]models.Blog, error) {
+	if len(blogIDs) == 0 {
+		return []models.Blog{}, nil
+	}
+	found, err := repositories.NewBlogRepository().GetBlogSummariesByIDs(blogIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Blog, len(found))
+	for i := range found {
+		byID[found[i].BlogID] = &found[i]
+	}
+	blogs := make([]models.Blog, 0, len(blogIDs))
+	for _, blogID := range blogIDs {
+		if blog, ok := byID[blogID]; ok && blog.Published() {
+			blogs = append(blogs, *blog)
+		}
+	}
+	return blogs, nil
+}
+
+// buildTimeline fills a timeline with the newest posts of a follow set
+func buildTimeline(key string, authorIDs, tags []string) error {
+	blogs, _, err := repositories.NewBlogRepository().GetFeedBlogs(authorIDs, tags, nil, config.FeedTimelineSize())
+	if err != nil || len(blogs) == 0 {
+		return err
+	}
+	members := make([]redis.Z, len(blogs))
+	for i := range blogs {
+		members[i] = redis.Z{Score: float64(blogs[i].CreatedAt.UnixMilli()), Member: blogs[i].BlogID}
+	}
+	ctx := context.Background()
+	pipe := db.RedisClient.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.ZAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, config.FeedTimelineTTL())
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// pushToTimelines adds a newly published blog to the timelines of the
+// followers of its author and tags. Only users following many have one,
+// the others query their feed on read.
+func pushToTimelines(blog *models.Blog) {
+	followerIDs, err := repositories.NewFollowRepository().ListFollowerIDs(blog.AuthorID, blog.Tags)
+	if err != nil {
+		log.Printf("Failed to push blog %s to timelines: %v", blog.BlogID, err)
+		return
+	}
+	if len(followerIDs) == 0 {
+		return
+	}
+	ctx := context.Background()
+	size := config.FeedTimelineSize()
+	pipe := db.RedisClient.Pipeline()
+	for _, followerID := range followerIDs {
+		timelinePush.Eval(ctx, pipe, []string{timelineKey(followerID)}, blog.CreatedAt.UnixMilli(), blog.BlogID, size)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to push blog %s to timelines: %v", blog.BlogID, err)
+	}
+}
+
+// dropTimelines forgets timelines, they are rebuilt on the next read
+func dropTimelines(userIDs ...string) {
+	if len(userIDs) == 0 {
+		return
+	}
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = timelineKey(userID)
+	}
+	db.RedisClient.Del(context.Background(), keys...)
+}
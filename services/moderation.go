@@ -0,0 +1,209 @@
+package services
+
+import (
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/spam"
+	"log"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var commentStatuses = []string{models.CommentPending, models.CommentSpam, models.CommentRejected, models.CommentApproved}
+
+// Status each moderation action moves a comment to
+var moderationActions = map[string]string{
+	"approve": models.CommentApproved,
+	"reject":  models.CommentRejected,
+	"spam":    models.CommentSpam,
+}
+
+// @Summary Get the moderation queue
+// @Description Editor only. Live comments of every blog in a status, oldest first, with their spam score.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Comment status" Enums(pending, spam, rejected, approved) default(pending)
+// @Param page query string false "Page number" default(1)
+// @Param limit query string false "Items per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Success 200 {object} models.GetModerationQueueResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/moderation/comments [get]
+// @Router /api/v2/moderation/comments [get]
+func GetModerationQueue(c *fiber.Ctx) error {
+	// Get query params
+	var errs []apperror.FieldError
+	page, limit := parsePagination(c, &errs)
+	status := c.Query("status", models.CommentPending)
+	if !slices.Contains(commentStatuses, status) {
+		errs = append(errs, apperror.Invalid("status", "status must be one of pending, spam, rejected, approved"))
+	}
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	comments, totalCount, err := repositories.NewCommentRepository().ListCommentsByStatus(status, page, limit)
+	if err != nil {
+		return err
+	}
+	queue := make([]*models.Comment, len(comments))
+	for i := range comments {
+		queue[i] = &comments[i]
+		queue[i].Replies = []*models.Comment{}
+	}
+	if err := embedCommentAuthors(queue); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get moderation queue successfully.",
+		Data: map[string]any{
+			"comments":    queue,
+			"status":      status,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+			"total_item":  totalCount,
+		},
+	})
+}
+
+// @Summary Moderate a comment
+// @Description Editor only. Approve a comment, reject it or mark it as spam. Approving or marking spam is reported to the spam scorer.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param comment_id path string true "Comment id"
+// @Param action path string true "Moderation action" Enums(approve, reject, spam)
+// @Success 200 {object} object{message=string,data=models.Comment}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/moderation/comments/{comment_id}/{action} [post]
+// @Router /api/v2/moderation/comments/{comment_id}/{action} [post]
+func ModerateComment(c *fiber.Ctx) error {
+	status, ok := moderationActions[c.Params("action")]
+	if !ok {
+		return apperror.Validation("Invalid moderation action.", apperror.Invalid("action", "action must be one of approve, reject, spam"))
+	}
+	comment, err := commentParam(c)
+	if err != nil {
+		return err
+	}
+	from := comment.Status
+	if from == "" {
+		from = models.CommentApproved
+	}
+	if from != status {
+		// Only move the comment if no other moderator did in between
+		changed, err := repositories.NewCommentRepository().SetCommentStatus(comment.CommentID, from, status)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return apperror.Conflict(apperror.CodeCommentStatusChanged, "Comment was moderated by someone else, reload and retry.")
+		}
+		comment.Status = status
+		delta := 0
+		switch {
+		case from == models.CommentApproved:
+			delta = -1
+		case status == models.CommentApproved:
+			delta = 1
+		}
+		if delta != 0 {
+			blogRepo := repositories.NewBlogRepository()
+			if err := blogRepo.IncCommentCount(comment.BlogID, delta); err != nil {
+				return err
+			}
+			// Comments count towards trending once they are visible
+			if delta > 0 {
+				blog, err := blogRepo.GetBlogByID(comment.BlogID)
+				if err != nil {
+					return err
+				}
+				if blog != nil {
+					trackActivity(blog, trendingCommentWeight)
+				}
+			}
+		}
+		invalidateCommentCaches(comment.BlogID, delta != 0)
+	}
+	// Rejections are about more than spam, don't teach the scorer with them
+	if status != models.CommentRejected {
+		report := spam.Comment{AuthorID: comment.AuthorID, BlogID: comment.BlogID, Content: comment.Content}
+		if err := spam.Report(report, status == models.CommentSpam); err != nil {
+			log.Printf("spam report failed for comment %s: %v", comment.CommentID, err)
+		}
+	}
+	comment.Replies = []*models.Comment{}
+	if err := embedCommentAuthors([]*models.Comment{comment}); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Comment moderated successfully.",
+		Data:    comment,
+	})
+}
+
+// @Summary Shadow-ban a user
+// @Description Editor only. The user's new comments are filed as spam while still looking published to them.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User id"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/moderation/users/{user_id}/shadow-ban [put]
+// @Router /api/v2/moderation/users/{user_id}/shadow-ban [put]
+func ShadowBanUser(c *fiber.Ctx) error {
+	return setShadowBan(c, true, "User shadow-banned successfully.")
+}
+
+// @Summary Lift a shadow ban
+// @Description Editor only. Comments filed as spam during the ban stay in the queue.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User id"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 401 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/moderation/users/{user_id}/shadow-ban [delete]
+// @Router /api/v2/moderation/users/{user_id}/shadow-ban [delete]
+func LiftShadowBan(c *fiber.Ctx) error {
+	return setShadowBan(c, false, "Shadow ban lifted successfully.")
+}
+
+func setShadowBan(c *fiber.Ctx, banned bool, message string) error {
+	userRepo := repositories.NewUserRepository()
+	user, err := userRepo.GetUserByUserID(c.Params("user_id"))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return apperror.NotFound(apperror.CodeUserNotFound, "User not found.")
+	}
+	if err := userRepo.UpdateUserFields(user.UserId, bson.M{"shadow_banned": banned}); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: message,
+	})
+}
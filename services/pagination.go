@@ -0,0 +1,27 @@
+package services
+
+import (
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parsePagination reads the page and limit query params. Problems are
+// appended to errs so callers can report every bad param at once.
+func parsePagination(c *fiber.Ctx, errs *[]apperror.FieldError) (int, int) {
+	page, err := strconv.Atoi(c.Query("page", "1"))
+	if err != nil || page < 1 {
+		*errs = append(*errs, apperror.Invalid("page", "page must be a positive integer"))
+		page = 1
+	}
+	maxLimit := config.MaxPageLimit()
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(config.DefaultPageLimit)))
+	if err != nil || limit < 1 || limit > maxLimit {
+		*errs = append(*errs, apperror.Invalid("limit", fmt.Sprintf("limit must be an integer between 1 and %d", maxLimit)))
+		limit = config.DefaultPageLimit
+	}
+	return page, limit
+}
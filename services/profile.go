@@ -0,0 +1,198 @@
+package services
+
+import (
+	"encoding/json"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"maps"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// @Summary Get a user's profile
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User id"
+// @Success 200 {object} object{message=string,data=models.UserProfile}
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/users/{user_id} [get]
+// @Router /api/v2/users/{user_id} [get]
+func GetUserProfile(c *fiber.Ctx) error {
+	userRepo := repositories.NewUserRepository()
+	user, err := userRepo.GetUserByUserID(c.Params("user_id"))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return apperror.NotFound(apperror.CodeUserNotFound, "User not found.")
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get user successfully.",
+		Data:    user.Profile(),
+	})
+}
+
+// @Summary Get my account
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{message=string,data=models.Account}
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me [get]
+// @Router /api/v2/me [get]
+func GetMe(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get account successfully.",
+		Data:    user.Account(),
+	})
+}
+
+// @Summary Update my profile
+// @Description Apply an RFC 7396 JSON Merge Patch to the profile. null or "" clears a field, a null social link removes it.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param profile body models.UpdateProfileRequest true "Merge patch of the profile"
+// @Success 200 {object} object{message=string,data=models.Account}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 415 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me [patch]
+// @Router /api/v2/me [patch]
+func UpdateMe(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return err
+	}
+	// Apply the patch to the editable fields
+	profile := &models.UpdateProfileRequest{}
+	if err := mergePatch(c, user.ProfileFields(), profile); err != nil {
+		return err
+	}
+	// Collect changes
+	changes := bson.M{}
+	if profile.DisplayName != user.DisplayName {
+		user.DisplayName = profile.DisplayName
+		changes["display_name"] = user.DisplayName
+	}
+	if profile.Bio != user.Bio {
+		user.Bio = profile.Bio
+		changes["bio"] = user.Bio
+	}
+	if profile.AvatarURL != user.AvatarURL {
+		user.AvatarURL = profile.AvatarURL
+		changes["avatar_url"] = user.AvatarURL
+	}
+	if !maps.Equal(profile.SocialLinks, user.SocialLinks) {
+		user.SocialLinks = profile.SocialLinks
+		changes["social_links"] = user.SocialLinks
+	}
+	if len(changes) > 0 {
+		if err := repositories.NewUserRepository().UpdateUserFields(user.UserId, changes); err != nil {
+			return err
+		}
+		// Cached lists embed the old author summary
+		invalidateListCaches()
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Profile updated successfully.",
+		Data:    user.Account(),
+	})
+}
+
+// currentUser loads the authenticated user
+func currentUser(c *fiber.Ctx) (*models.User, error) {
+	userID, _ := c.Locals("userId").(string)
+	user, err := repositories.NewUserRepository().GetUserByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	// The token outlived its user
+	if user == nil {
+		return nil, apperror.Unauthorized(apperror.CodeUnauthorized, "Unauthorized.")
+	}
+	return user, nil
+}
+
+// parseEmbed reads the embed query param, only the author can be embedded
+func parseEmbed(c *fiber.Ctx, errs *[]apperror.FieldError) bool {
+	switch c.Query("embed") {
+	case "":
+		return false
+	case "author":
+		return true
+	}
+	*errs = append(*errs, apperror.Invalid("embed", "embed must be author"))
+	return false
+}
+
+// mergePatch applies the JSON merge patch in the request body to current
+// and decodes the result into req like a request body
+func mergePatch(c *fiber.Ctx, current any, req any) error {
+	mediaType := strings.TrimSpace(strings.Split(c.Get(fiber.HeaderContentType), ";")[0])
+	if mediaType != "application/merge-patch+json" && mediaType != fiber.MIMEApplicationJSON {
+		return apperror.New(fiber.StatusUnsupportedMediaType, apperror.CodeUnsupportedMediaType, "Unsupported patch format "+mediaType+".")
+	}
+	original, _ := json.Marshal(current)
+	patched, err := jsonpatch.MergePatch(original, c.Body())
+	if err != nil {
+		return apperror.BadRequest(apperror.CodeInvalidPatch, "Invalid patch: "+err.Error())
+	}
+	return decodeRequest(patched, req)
+}
+
+// embedAuthors sets the author summary of every blog with a single query
+func embedAuthors(blogs ...*models.Blog) error {
+	authorIDs := make([]string, len(blogs))
+	for i, blog := range blogs {
+		authorIDs[i] = blog.AuthorID
+	}
+	authors, err := loadAuthors(authorIDs)
+	if err != nil {
+		return err
+	}
+	for _, blog := range blogs {
+		blog.Author = authors[blog.AuthorID]
+	}
+	return nil
+}
+
+// loadAuthors returns the author summaries of the given users by id.
+// Missing users are left out.
+func loadAuthors(userIDs []string) (map[string]*models.AuthorSummary, error) {
+	authorIDs := make([]string, 0, len(userIDs))
+	seen := map[string]bool{}
+	for _, userID := range userIDs {
+		if userID != "" && !seen[userID] {
+			seen[userID] = true
+			authorIDs = append(authorIDs, userID)
+		}
+	}
+	authors := make(map[string]*models.AuthorSummary, len(authorIDs))
+	if len(authorIDs) == 0 {
+		return authors, nil
+	}
+	users, err := repositories.NewUserRepository().GetUsersByIDs(authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		author := users[i].AuthorSummary()
+		authors[users[i].UserId] = &author
+	}
+	return authors, nil
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Blogs whose reaction counts changed since the last flush
+const reactionsDirtyKey = "blog:reactions:dirty"
+
+func reactionsKey(blogID string) string {
+	return fmt.Sprintf("blog:reactions:%s", blogID)
+}
+
+// @Summary React to a blog
+// @Description Add a reaction from REACTIONS, like by default. Reacting twice the same way changes nothing.
+// @Tags reactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param blog_id path string true "Blog id"
+// @Param reaction path string true "Reaction name, e.g. like"
+// @Success 200 {object} object{message=string,data=models.BlogReactions}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/blogs/{blog_id}/reactions/{reaction} [put]
+// @Router /api/v2/blogs/{blog_id}/reactions/{reaction} [put]
+func AddReaction(c *fiber.Ctx) error {
+	return setReaction(c, true, "Reaction added successfully.")
+}
+
+// @Summary Remove a reaction
+// @Description Removing a reaction that isn't there changes nothing.
+// @Tags reactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param blog_id path string true "Blog id"
+// @Param reaction path string true "Reaction name, e.g. like"
+// @Success 200 {object} object{message=string,data=models.BlogReactions}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/blogs/{blog_id}/reactions/{reaction} [delete]
+// @Router /api/v2/blogs/{blog_id}/reactions/{reaction} [delete]
+func RemoveReaction(c *fiber.Ctx) error {
+	return setReaction(c, false, "Reaction removed successfully.")
+}
+
+func setReaction(c *fiber.Ctx, add bool, message string) error {
+	userID := c.Locals("userId").(string)
+	blogID, err := blogIDParam(c)
+	if err != nil {
+		return err
+	}
+	reaction := strings.ToLower(c.Params("reaction"))
+	if reactions := config.Reactions(); !slices.Contains(reactions, reaction) {
+		return apperror.Validation("Invalid reaction.", apperror.Invalid("reaction", "reaction must be one of "+strings.Join(reactions, ", ")))
+	}
+	blog, err := repositories.NewBlogRepository().GetBlogByID(blogID)
+	if err != nil {
+		return err
+	}
+	if blog == nil || !canView(blog, userID) {
+		return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
+	}
+	// The stored reaction decides whether the count moves, so repeats are no-ops
+	reactionRepo := repositories.NewReactionRepository()
+	var changed bool
+	delta := int64(1)
+	if add {
+		changed, err = reactionRepo.AddReaction(&models.Reaction{
+			BlogID:    blogID,
+			UserID:    userID,
+			Reaction:  reaction,
+			CreatedAt: time.Now(),
+		})
+	} else {
+		changed, err = reactionRepo.RemoveReaction(blogID, userID, reaction)
+		delta = -1
+	}
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := adjustReactionCount(blogID, reaction, delta); err != nil {
+			return err
+		}
+		trackActivity(blog, float64(delta*trendingReactionWeight))
+	}
+	counts, mine, err := blogReactions(blogID, userID)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: message,
+		Data:    models.BlogReactions{Reactions: counts, MyReactions: mine},
+	})
+}
+
+// withReactions sets the live reaction counts of a blog, and the current
+// user's flags when logged in
+func withReactions(c *fiber.Ctx, blog *models.Blog) error {
+	userID, _ := c.Locals("userId").(string)
+	counts, mine, err := blogReactions(blog.BlogID, userID)
+	if err != nil {
+		return err
+	}
+	blog.Reactions = counts
+	blog.LikeCount = counts[models.ReactionLike]
+	blog.MyReactions = mine
+	return nil
+}
+
+// blogReactions returns a blog's counts for every configured reaction and,
+// given a user, which ones they left
+func blogReactions(blogID, userID string) (map[string]int64, map[string]bool, error) {
+	stored, err := reactionCounts(blogID)
+	if err != nil {
+		return nil, nil, err
+	}
+	reactions := config.Reactions()
+	counts := make(map[string]int64, len(reactions))
+	for _, reaction := range reactions {
+		counts[reaction] = stored[reaction]
+	}
+	if userID == "" {
+		return counts, nil, nil
+	}
+	left, err := repositories.NewReactionRepository().GetUserReactions(blogID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	mine := make(map[string]bool, len(reactions))
+	for _, reaction := range reactions {
+		mine[reaction] = slices.Contains(left, reaction)
+	}
+	return counts, mine, nil
+}
+
+// reactionCounts reads a blog's counts from Redis, rebuilding them from
+// the stored reactions when Redis lost them
+func reactionCounts(blogID string) (map[string]int64, error) {
+	values, err := db.RedisClient.HGetAll(context.Background(), reactionsKey(blogID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return rebuildReactionCounts(blogID)
+	}
+	counts := make(map[string]int64, len(values))
+	for reaction, value := range values {
+		counts[reaction], _ = strconv.ParseInt(value, 10, 64)
+	}
+	return counts, nil
+}
+
+func rebuildReactionCounts(blogID string) (map[string]int64, error) {
+	counts, err := repositories.NewReactionRepository().CountReactions(blogID)
+	if err != nil {
+		return nil, err
+	}
+	// Store every configured reaction so blogs without any still have a hash
+	values := map[string]any{}
+	for _, reaction := range config.Reactions() {
+		values[reaction] = counts[reaction]
+	}
+	for reaction, count := range counts {
+		values[reaction] = count
+	}
+	if err := db.RedisClient.HSet(context.Background(), reactionsKey(blogID), values).Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// adjustReactionCount moves a count after a reaction was stored or removed
+// and marks the blog for the next flush
+func adjustReactionCount(blogID, reaction string, delta int64) error {
+	exists, err := db.RedisClient.Exists(context.Background(), reactionsKey(blogID)).Result()
+	if err != nil {
+		return err
+	}
+	// A rebuilt hash already includes the change
+	if exists == 0 {
+		_, err = rebuildReactionCounts(blogID)
+	} else {
+		err = db.RedisClient.HIncrBy(context.Background(), reactionsKey(blogID), reaction, delta).Err()
+	}
+	if err != nil {
+		return err
+	}
+	return db.RedisClient.SAdd(context.Background(), reactionsDirtyKey, blogID).Err()
+}
+
+// recountReactions has the counts of blogs rebuilt from the stored
+// reactions on their next read and flush, after reactions were removed in
+// bulk. Running it twice is harmless.
+func recountReactions(blogIDs []string) error {
+	keys := make([]string, len(blogIDs))
+	members := make([]any, len(blogIDs))
+	for i, blogID := range blogIDs {
+		keys[i] = reactionsKey(blogID)
+		members[i] = blogID
+	}
+	if err := db.RedisClient.SAdd(context.Background(), reactionsDirtyKey, members...).Err(); err != nil {
+		return err
+	}
+	return db.RedisClient.Del(context.Background(), keys...).Err()
+}
+
+// dropReactionCounts forgets the counts of deleted blogs
+func dropReactionCounts(blogIDs []string) {
+	keys := make([]string, len(blogIDs))
+	members := make([]any, len(blogIDs))
+	for i, blogID := range blogIDs {
+		keys[i] = reactionsKey(blogID)
+		members[i] = blogID
+	}
+	db.RedisClient.Del(context.Background(), keys...)
+	db.RedisClient.SRem(context.Background(), reactionsDirtyKey, members...)
+}
+
+// FlushReactionCounts copies the counts of every blog reacted to since the
+// last flush to MongoDB, where lists sort by them.
+func FlushReactionCounts() error {
+	blogRepo := repositories.NewBlogRepository()
+	flushed := 0
+	for {
+		blogID, err := db.RedisClient.SPop(context.Background(), reactionsDirtyKey).Result()
+		if errors.Is(err, redis.Nil) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		counts, err := reactionCounts(blogID)
+		if err == nil {
+			err = blogRepo.SetReactionCounts(blogID, counts)
+		}
+		if err != nil {
+			// Retry on the next flush
+			db.RedisClient.SAdd(context.Background(), reactionsDirtyKey, blogID)
+			return err
+		}
+		flushed++
+	}
+	// Cached lists show and sort by the old counts
+	if flushed > 0 {
+		for _, pattern := range []string{"blog:list:*", "blog:search:*"} {
+			keys, _ := db.RedisClient.Keys(context.Background(), pattern).Result()
+			if len(keys) > 0 {
+				db.RedisClient.Del(context.Background(), keys...)
+			}
+		}
+	}
+	return nil
+}
+
+// StartReactionFlusher flushes reaction counts every interval until the
+// process exits. Counts not flushed yet stay in Redis for the next run.
+func StartReactionFlusher(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if err := FlushReactionCounts(); err != nil {
+				log.Printf("Failed to flush reaction counts: %v", err)
+			}
+		}
+	}()
+}
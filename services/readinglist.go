@@ -0,0 +1,598 @@
+package services
+
+import (
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/utils"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// @Summary Get my bookmarks
+// @Description Bookmarked blogs, newest bookmark first. Deleted blogs and drafts are left out and counted in hidden.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query string false "Page number" default(1)
+// @Param limit query string false "Items per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Success 200 {object} models.GetReadingListResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/bookmarks [get]
+// @Router /api/v2/me/bookmarks [get]
+func GetBookmarks(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	var errs []apperror.FieldError
+	page, limit := parsePagination(c, &errs)
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	bookmarks, totalCount, err := repositories.NewBookmarkRepository().ListBookmarks(userID, page, limit)
+	if err != nil {
+		return err
+	}
+	blogIDs := make([]string, len(bookmarks))
+	for i := range bookmarks {
+		blogIDs[i] = bookmarks[i].BlogID
+	}
+	blogs, hidden, err := resolveBlogs(blogIDs, userID)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get bookmarks successfully.",
+		Data:    resolvedPage(nil, blogs, hidden, page, limit, totalCount),
+	})
+}
+
+// @Summary Bookmark a blog
+// @Description Bookmarking a blog twice changes nothing.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param blog_id path string true "Blog id"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/bookmarks/{blog_id} [put]
+// @Router /api/v2/me/bookmarks/{blog_id} [put]
+func AddBookmark(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	blog, err := visibleBlogParam(c, userID)
+	if err != nil {
+		return err
+	}
+	_, err = repositories.NewBookmarkRepository().AddBookmark(&models.Bookmark{
+		UserID:    userID,
+		BlogID:    blog.BlogID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: "Blog bookmarked successfully.",
+	})
+}
+
+// @Summary Remove a bookmark
+// @Description Removing a bookmark that isn't there changes nothing.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param blog_id path string true "Blog id"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/bookmarks/{blog_id} [delete]
+// @Router /api/v2/me/bookmarks/{blog_id} [delete]
+func RemoveBookmark(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	blogID, err := blogIDParam(c)
+	if err != nil {
+		return err
+	}
+	if err := repositories.NewBookmarkRepository().RemoveBookmark(userID, blogID); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: "Bookmark removed successfully.",
+	})
+}
+
+// @Summary Get my reading lists
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{message=string,data=[]models.ReadingList}
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/lists [get]
+// @Router /api/v2/me/lists [get]
+func GetReadingLists(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	lists, err := repositories.NewReadingListRepository().ListsByUser(userID)
+	if err != nil {
+		return err
+	}
+	for i := range lists {
+		setShareURL(&lists[i])
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get reading lists successfully.",
+		Data:    lists,
+	})
+}
+
+// @Summary Create a reading list
+// @Description Public lists get a share_url anyone can open.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param list body models.ReadingListRequest true "Reading list"
+// @Success 201 {object} object{message=string,data=models.ReadingList} "Created, v2"
+// @Success 200 {object} object{message=string,data=models.ReadingList} "Created, v1"
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/lists [post]
+// @Router /api/v2/me/lists [post]
+func CreateReadingList(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	// Extract and validate body
+	body := &models.ReadingListRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	now := time.Now()
+	list := &models.ReadingList{
+		ListID:      utils.GenerateID(),
+		UserID:      userID,
+		Name:        body.Name,
+		Description: body.Description,
+		Public:      body.Public,
+		BlogIDs:     []string{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if list.Public {
+		list.ShareToken = utils.GenerateSecret()
+	}
+	if err := repositories.NewReadingListRepository().InsertList(list); err != nil {
+		return err
+	}
+	setShareURL(list)
+	return created(c, "/api/v2/me/lists/"+list.ListID).JSON(models.ResponseData{
+		Message: "Reading list created successfully.",
+		Data:    list,
+	})
+}
+
+// @Summary Get one of my reading lists
+// @Description The list with a page of its blogs in list order. Deleted blogs and drafts are left out and counted in hidden.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param list_id path string true "Reading list id"
+// @Param page query string false "Page number" default(1)
+// @Param limit query string false "Items per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Success 200 {object} models.GetReadingListResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/lists/{list_id} [get]
+// @Router /api/v2/me/lists/{list_id} [get]
+func GetReadingList(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	list, err := ownListParam(c, userID)
+	if err != nil {
+		return err
+	}
+	return sendReadingList(c, list, userID)
+}
+
+// @Summary Get a shared reading list
+// @Description Anyone with the share link of a public list can read it. The owner and the ids of blogs readers can't see are left out.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Param token path string true "Share token from share_url"
+// @Param page query string false "Page number" default(1)
+// @Param limit query string false "Items per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Success 200 {object} models.GetSharedReadingListResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/shared-lists/{token} [get]
+// @Router /api/v2/shared-lists/{token} [get]
+func GetSharedReadingList(c *fiber.Ctx) error {
+	list, err := repositories.NewReadingListRepository().GetListByShareToken(c.Params("token"))
+	if err != nil {
+		return err
+	}
+	if list == nil || !list.Public {
+		return apperror.NotFound(apperror.CodeReadingListNotFound, "Reading list not found.")
+	}
+	return sendReadingList(c, list, "")
+}
+
+// @Summary Update a reading list
+// @Description JSON merge patch of name, description and public. Making a list private disables its share link until it is public again.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param list_id path string true "Reading list id"
+// @Param list body models.ReadingListRequest true "Fields to change"
+// @Success 200 {object} object{message=string,data=models.ReadingList}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 415 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/lists/{list_id} [patch]
+// @Router /api/v2/me/lists/{list_id} [patch]
+func UpdateReadingList(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	list, err := ownListParam(c, userID)
+	if err != nil {
+		return err
+	}
+	body := &models.ReadingListRequest{}
+	if err := mergePatch(c, list.Fields(), body); err != nil {
+		return err
+	}
+	// Collect changes
+	changes := bson.M{}
+	if body.Name != list.Name {
+		list.Name = body.Name
+		changes["name"] = list.Name
+	}
+	if body.Description != list.Description {
+		list.Description = body.Description
+		changes["description"] = list.Description
+	}
+	if body.Public != list.Public {
+		list.Public = body.Public
+		changes["public"] = list.Public
+		if list.Public && list.ShareToken == "" {
+			list.ShareToken = utils.GenerateSecret()
+			changes["share_token"] = list.ShareToken
+		}
+	}
+	if len(changes) > 0 {
+		list.UpdatedAt = time.Now()
+		changes["updated_at"] = list.UpdatedAt
+		if err := repositories.NewReadingListRepository().UpdateListFields(list.ListID, changes); err != nil {
+			return err
+		}
+	}
+	setShareURL(list)
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Reading list updated successfully.",
+		Data:    list,
+	})
+}
+
+// @Summary Delete a reading list
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param list_id path string true "Reading list id"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/lists/{list_id} [delete]
+// @Router /api/v2/me/lists/{list_id} [delete]
+func DeleteReadingList(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	list, err := ownListParam(c, userID)
+	if err != nil {
+		return err
+	}
+	if err := repositories.NewReadingListRepository().DeleteList(list.ListID); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: "Reading list deleted successfully.",
+	})
+}
+
+// @Summary Add a blog to a reading list
+// @Description The blog goes to the end of the list. Adding a blog already in the list changes nothing.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param list_id path string true "Reading list id"
+// @Param blog_id path string true "Blog id"
+// @Success 200 {object} object{message=string,data=models.ReadingList}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/lists/{list_id}/items/{blog_id} [put]
+// @Router /api/v2/me/lists/{list_id}/items/{blog_id} [put]
+func AddReadingListItem(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	list, err := ownListParam(c, userID)
+	if err != nil {
+		return err
+	}
+	blog, err := visibleBlogParam(c, userID)
+	if err != nil {
+		return err
+	}
+	listRepo := repositories.NewReadingListRepository()
+	if !slices.Contains(list.BlogIDs, blog.BlogID) {
+		added, err := listRepo.AddItem(list.ListID, blog.BlogID)
+		if err != nil {
+			return err
+		}
+		// Not added means the list filled up or already got the blog
+		if !added {
+			if list, err = listRepo.GetList(list.ListID); err != nil {
+				return err
+			}
+			if list == nil {
+				return apperror.NotFound(apperror.CodeReadingListNotFound, "Reading list not found.")
+			}
+			if !slices.Contains(list.BlogIDs, blog.BlogID) {
+				return apperror.Conflict(apperror.CodeReadingListFull, fmt.Sprintf("Reading lists hold at most %d blogs.", models.MaxReadingListItems))
+			}
+		} else {
+			list.BlogIDs = append(list.BlogIDs, blog.BlogID)
+			list.UpdatedAt = time.Now()
+		}
+	}
+	setShareURL(list)
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Blog added to reading list successfully.",
+		Data:    list,
+	})
+}
+
+// @Summary Remove a blog from a reading list
+// @Description Removing a blog that isn't in the list changes nothing.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param list_id path string true "Reading list id"
+// @Param blog_id path string true "Blog id"
+// @Success 200 {object} object{message=string,data=models.ReadingList}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/lists/{list_id}/items/{blog_id} [delete]
+// @Router /api/v2/me/lists/{list_id}/items/{blog_id} [delete]
+func RemoveReadingListItem(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	list, err := ownListParam(c, userID)
+	if err != nil {
+		return err
+	}
+	blogID, err := blogIDParam(c)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(list.BlogIDs, blogID) {
+		if err := repositories.NewReadingListRepository().RemoveItem(list.ListID, blogID); err != nil {
+			return err
+		}
+		list.BlogIDs = slices.DeleteFunc(list.BlogIDs, func(id string) bool { return id == blogID })
+		list.UpdatedAt = time.Now()
+	}
+	setShareURL(list)
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Blog removed from reading list successfully.",
+		Data:    list,
+	})
+}
+
+// @Summary Reorder a reading list
+// @Description blog_ids must hold exactly the blogs of the list, in the new order.
+// @Tags reading lists
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param list_id path string true "Reading list id"
+// @Param order body models.ReorderReadingListRequest true "Blog ids in the new order"
+// @Success 200 {object} object{message=string,data=models.ReadingList}
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/lists/{list_id}/items [put]
+// @Router /api/v2/me/lists/{list_id}/items [put]
+func ReorderReadingList(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	list, err := ownListParam(c, userID)
+	if err != nil {
+		return err
+	}
+	// Extract and validate body
+	body := &models.ReorderReadingListRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	blogIDs := make([]string, len(body.BlogIDs))
+	for i, blogID := range body.BlogIDs {
+		blogIDs[i], _ = utils.NormalizeID(blogID)
+	}
+	if !samePermutation(blogIDs, list.BlogIDs) {
+		return apperror.Validation("Invalid body.", apperror.Invalid("blog_ids", "blog_ids must hold exactly the blogs of the list"))
+	}
+	reordered, err := repositories.NewReadingListRepository().ReorderItems(list.ListID, blogIDs)
+	if err != nil {
+		return err
+	}
+	if !reordered {
+		return apperror.Conflict(apperror.CodeReadingListChanged, "Reading list was changed, reload and retry.")
+	}
+	list.BlogIDs = blogIDs
+	list.UpdatedAt = time.Now()
+	setShareURL(list)
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Reading list reordered successfully.",
+		Data:    list,
+	})
+}
+
+// removeSavedBlogs takes deleted blogs out of bookmarks and reading lists
+func removeSavedBlogs(blogIDs []string) error {
+	if err := repositories.NewBookmarkRepository().DeleteBookmarksByBlogs(blogIDs); err != nil {
+		return err
+	}
+	return repositories.NewReadingListRepository().RemoveBlogsFromLists(blogIDs)
+}
+
+// ownListParam loads the list named by the list_id path param, which must
+// belong to userID
+func ownListParam(c *fiber.Ctx, userID string) (*models.ReadingList, error) {
+	listID, ok := utils.NormalizeID(c.Params("list_id"))
+	if !ok {
+		return nil, apperror.BadRequest(apperror.CodeInvalidListID, "Invalid reading list id.")
+	}
+	list, err := repositories.NewReadingListRepository().GetList(listID)
+	if err != nil {
+		return nil, err
+	}
+	// Other users' lists don't exist as far as the caller knows
+	if list == nil || list.UserID != userID {
+		return nil, apperror.NotFound(apperror.CodeReadingListNotFound, "Reading list not found.")
+	}
+	return list, nil
+}
+
+// visibleBlogParam loads the blog named by the blog_id path param if the
+// user can see it
+func visibleBlogParam(c *fiber.Ctx, userID string) (*models.Blog, error) {
+	blogID, err := blogIDParam(c)
+	if err != nil {
+		return nil, err
+	}
+	blog, err := repositories.NewBlogRepository().GetBlogByID(blogID)
+	if err != nil {
+		return nil, err
+	}
+	if blog == nil || !canView(blog, userID) {
+		return nil, apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
+	}
+	return blog, nil
+}
+
+// sendReadingList responds with a list and a page of its blogs
+func sendReadingList(c *fiber.Ctx, list *models.ReadingList, viewerID string) error {
+	var errs []apperror.FieldError
+	page, limit := parsePagination(c, &errs)
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	start := min((page-1)*limit, len(list.BlogIDs))
+	end := min(start+limit, len(list.BlogIDs))
+	blogs, hidden, err := resolveBlogs(list.BlogIDs[start:end], viewerID)
+	if err != nil {
+		return err
+	}
+	setShareURL(list)
+	// Readers of a shared list only learn what its owner chose to show
+	var shown any = list
+	if viewerID != list.UserID {
+		shown = list.Shared()
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get reading list successfully.",
+		Data:    resolvedPage(shown, blogs, hidden, page, limit, int64(len(list.BlogIDs))),
+	})
+}
+
+// resolveBlogs loads the summaries of blogIDs in one query, keeping their
+// order. Deleted blogs and drafts the viewer can't see are skipped and
+// counted as hidden.
+func resolveBlogs(blogIDs []string, viewerID string) ([]models.BlogSummary, int, error) {
+	summaries := []models.BlogSummary{}
+	if len(blogIDs) == 0 {
+		return summaries, 0, nil
+	}
+	blogs, err := repositories.NewBlogRepository().GetBlogSummariesByIDs(blogIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]*models.Blog, len(blogs))
+	for i := range blogs {
+		byID[blogs[i].BlogID] = &blogs[i]
+	}
+	for _, blogID := range blogIDs {
+		if blog, ok := byID[blogID]; ok && canView(blog, viewerID) {
+			summaries = append(summaries, blog.Summary())
+		}
+	}
+	return summaries, len(blogIDs) - len(summaries), nil
+}
+
+func resolvedPage(list any, blogs []models.BlogSummary, hidden, page, limit int, totalCount int64) map[string]any {
+	data := map[string]any{
+		"blogs":       blogs,
+		"hidden":      hidden,
+		"page":        page,
+		"limit":       limit,
+		"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		"total_item":  totalCount,
+	}
+	if list != nil {
+		data["list"] = list
+	}
+	return data
+}
+
+// setShareURL fills in the share link of public lists
+func setShareURL(list *models.ReadingList) {
+	list.ShareURL = ""
+	if list.Public && list.ShareToken != "" {
+		list.ShareURL = fmt.Sprintf("%s/lists/shared/%s", config.SiteURL(), list.ShareToken)
+	}
+}
+
+// samePermutation reports whether a holds the same ids as b, each once
+func samePermutation(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, id := range a {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
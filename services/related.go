@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/related"
+	"inkinkink111/go-blog-management/repositories"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// Related blogs kept per blog, the most the endpoint returns
+	maxRelatedBlogs     = 10
+	defaultRelatedBlogs = 5
+	// Blogs waiting for their related blogs to be recomputed
+	relatedQueueSize = 1024
+)
+
+// Ranking scores the whole corpus, so writes queue it instead of waiting
+var relatedQueue = make(chan relatedJob, relatedQueueSize)
+
+// relatedJob asks for the related blogs around a written or deleted blog
+// to be recomputed. before is the blog as it was matched until the write,
+// empty when it wasn't published.
+type relatedJob struct {
+	blogID string
+	before related.Document
+}
+
+func relatedKey(blogID string) string {
+	return fmt.Sprintf("blog:related:%s", blogID)
+}
+
+// @Summary Get related blogs
+// @Description Published blogs sharing the most tags and title words with the blog, rare ones counting more. Drafts are only found by their author.
+// @Tags blogs
+// @Accept json
+// @Produce json
+// @Param blog_id path string true "Blog id"
+// @Param limit query int false "Number of blogs" default(5) maximum(10)
+// @Success 200 {object} models.GetRelatedResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/blogs/{blog_id}/related [get]
+// @Router /api/v2/blogs/{blog_id}/related [get]
+func GetRelatedBlogs(c *fiber.Ctx) error {
+	blogID, err := blogIDParam(c)
+	if err != nil {
+		return err
+	}
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultRelatedBlogs)))
+	if err != nil || limit < 1 || limit > maxRelatedBlogs {
+		return apperror.Validation("Invalid query params.", apperror.Invalid("limit", fmt.Sprintf("limit must be an integer between 1 and %d", maxRelatedBlogs)))
+	}
+	viewerID, _ := c.Locals("userId").(string)
+	blog, err := repositories.NewBlogRepository().GetBlogByID(blogID)
+	if err != nil {
+		return err
+	}
+	if blog == nil || !canView(blog, viewerID) {
+		return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
+	}
+	matches, err := relatedMatches(blog)
+	if err != nil {
+		return err
+	}
+	blogs, err := relatedBlogs(matches, limit)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get related blogs successfully.",
+		Data: map[string]any{
+			"blogs": blogs,
+			"limit": limit,
+		},
+	})
+}
+
+// relatedMatches returns the cached related blogs of blog, computing them
+// when they are missing
+func relatedMatches(blog *models.Blog) ([]related.Match, error) {
+	if matches := cachedRelated(blog.BlogID); matches != nil {
+		return matches, nil
+	}
+	corpus, err := relatedCorpus()
+	if err != nil {
+		return nil, err
+	}
+	matches := related.Rank(relatedDocument(blog), corpus, maxRelatedBlogs)
+	storeRelated(blog.BlogID, matches)
+	return matches, nil
+}
+
+// relatedCorpus is every published blog as related.Rank compares them
+func relatedCorpus() ([]related.Document, error) {
+	blogs, err := repositories.NewBlogRepository().ListPublishedTitles()
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]related.Document, len(blogs))
+	for i := range blogs {
+		documents[i] = relatedDocument(&blogs[i])
+	}
+	return documents, nil
+}
+
+func relatedDocument(blog *models.Blog) related.Document {
+	return related.Document{ID: blog.BlogID, Title: blog.Title, Tags: blog.Tags}
+}
+
+func storeRelated(blogID string, matches []related.Match) {
+	value, _ := json.Marshal(matches[:min(len(matches), maxRelatedBlogs)])
+	db.RedisClient.Set(context.Background(), relatedKey(blogID), value, 7*24*time.Hour)
+}
+
+// relatedBlogs loads matched blogs in order, skipping the ones deleted or
+// unpublished since the matches were cached
+func relatedBlogs(matches []related.Match, limit int) ([]models.RelatedBlog, error) {
+	blogs := []models.RelatedBlog{}
+	if len(matches) == 0 {
+		return blogs, nil
+	}
+	blogIDs := make([]string, len(matches))
+	for i := range matches {
+		blogIDs[i] = matches[i].BlogID
+	}
+	found, err := repositories.NewBlogRepository().GetBlogSummariesByIDs(blogIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Blog, len(found))
+	for i := range found {
+		byID[found[i].BlogID] = &found[i]
+	}
+	for _, match := range matches {
+		blog, ok := byID[match.BlogID]
+		if !ok || !blog.Published() {
+			continue
+		}
+		blogs = append(blogs, models.RelatedBlog{BlogSummary: blog.Summary(), RelatedScore: match.Score})
+		if len(blogs) == limit {
+			break
+		}
+	}
+	return blogs, nil
+}
+
+// queueRelated has the related blogs around a blog recomputed after a
+// write, given the blog as it was before. When the queue is full its list
+// is only dropped, the next read computes it.
+func queueRelated(blogID string, before related.Document) {
+	select {
+	case relatedQueue <- relatedJob{blogID: blogID, before: before}:
+	default:
+		db.RedisClient.Del(context.Background(), relatedKey(blogID))
+	}
+}
+
+// StartRelatedWorker recomputes the related blogs around queued blogs
+// until the process exits.
+func StartRelatedWorker() {
+	go func() {
+		for job := range relatedQueue {
+			if err := refreshRelated(job); err != nil {
+				log.Printf("Failed to compute related blogs around %s: %v", job.blogID, err)
+				db.RedisClient.Del(context.Background(), relatedKey(job.blogID))
+			}
+		}
+	}()
+}
+
+// refreshRelated recomputes the related blogs of a blog, reloaded so the
+// latest write wins, and of every blog whose list it may have entered or
+// left: the blogs sharing a term with it before or after the write, and
+// the ones it listed. Their lists are rewritten rather than dropped, so
+// reads never rank the corpus after a write.
+func refreshRelated(job relatedJob) error {
+	blog, err := repositories.NewBlogRepository().GetBlogByID(job.blogID)
+	if err != nil {
+		return err
+	}
+	corpus, err := relatedCorpus()
+	if err != nil {
+		return err
+	}
+	affected := map[string]bool{}
+	for _, match := range cachedRelated(job.blogID) {
+		affected[match.BlogID] = true
+	}
+	if job.before.ID != "" {
+		for _, match := range related.Rank(job.before, corpus, 0) {
+			affected[match.BlogID] = true
+		}
+	}
+	var stale []string
+	if blog != nil && blog.Published() {
+		all := related.Rank(relatedDocument(blog), corpus, 0)
+		storeRelated(blog.BlogID, all)
+		for _, match := range all {
+			affected[match.BlogID] = true
+		}
+	} else {
+		stale = append(stale, relatedKey(job.blogID))
+	}
+	published := make(map[string]related.Document, len(corpus))
+	for _, doc := range corpus {
+		published[doc.ID] = doc
+	}
+	for blogID := range affected {
+		doc, ok := published[blogID]
+		if !ok {
+			stale = append(stale, relatedKey(blogID))
+			continue
+		}
+		storeRelated(blogID, related.Rank(doc, corpus, maxRelatedBlogs))
+	}
+	if len(stale) > 0 {
+		db.RedisClient.Del(context.Background(), stale...)
+	}
+	return nil
+}
+
+// cachedRelated returns the related blogs cached for a blog, if any
+func cachedRelated(blogID string) []related.Match {
+	cached, err := db.RedisClient.Get(context.Background(), relatedKey(blogID)).Result()
+	if err != nil {
+		return nil
+	}
+	var matches []related.Match
+	if json.Unmarshal([]byte(cached), &matches) != nil {
+		return nil
+	}
+	return matches
+}
+
+// forgetRelated has the related blogs around deleted blogs recomputed,
+// finding the blogs that listed them from their own lists
+func forgetRelated(blogIDs []string) {
+	for _, blogID := range blogIDs {
+		queueRelated(blogID, related.Document{})
+	}
+}
+
+// dropAllRelated forgets every cached related list, after tags changed
+// across many blogs
+func dropAllRelated() {
+	keys, _ := db.RedisClient.Keys(context.Background(), "blog:related:*").Result()
+	if len(keys) > 0 {
+		db.RedisClient.Del(context.Background(), keys...)
+	}
+}
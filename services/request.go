@@ -0,0 +1,200 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/utils"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const maxTagLength = 50
+
+// Normalized tags start with a letter or digit, e.g. golang, node.js, c++
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}.+#-]*$`)
+
+// Fields owned by the server, reported as forbidden rather than unknown
+var forbiddenFields = map[string]bool{
+	"id":            true,
+	"_id":           true,
+	"blog_id":       true,
+	"user_id":       true,
+	"author_id":     true,
+	"role":          true,
+	"slug":          true,
+	"version":       true,
+	"popularity":    true,
+	"reactions":     true,
+	"like_count":    true,
+	"comment_count": true,
+	"created_at":    true,
+	"updated_at":    true,
+	"content_html":  true,
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by their json names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterValidation("tag", func(fl validator.FieldLevel) bool {
+		tag := utils.NormalizeTag(fl.Field().String())
+		return tag != "" && len(tag) <= maxTagLength && tagPattern.MatchString(tag)
+	})
+	return v
+}
+
+// created sets the status of a successful create: 201 with a Location
+// header on v2, the 200 v1 clients were promised on v1
+func created(c *fiber.Ctx, location string) *fiber.Ctx {
+	if version, _ := c.Locals("apiVersion").(string); version == "v1" {
+		return c.Status(fiber.StatusOK)
+	}
+	c.Location(location)
+	return c.Status(fiber.StatusCreated)
+}
+
+// parseBody decodes the JSON request body into req and validates it. See
+// decodeRequest.
+func parseBody(c *fiber.Ctx, req any) error {
+	contentType := strings.TrimSpace(strings.Split(c.Get(fiber.HeaderContentType), ";")[0])
+	if contentType != "" && contentType != fiber.MIMEApplicationJSON && !strings.HasSuffix(contentType, "+json") {
+		return apperror.New(fiber.StatusUnsupportedMediaType, apperror.CodeUnsupportedMediaType, "Request body must be JSON.")
+	}
+	return decodeRequest(c.Body(), req)
+}
+
+// decodeRequest decodes a JSON object into the struct pointed to by req.
+// Unknown fields, server-owned fields, values of the wrong type and
+// failed validate tags are all reported together.
+func decodeRequest(data []byte, req any) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		return apperror.BadRequest(apperror.CodeInvalidBody, "Request body must be a JSON object.")
+	}
+	target := reflect.ValueOf(req).Elem()
+	fields := jsonFields(target.Type())
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []apperror.FieldError
+	reported := map[string]bool{}
+	for _, name := range names {
+		index, ok := fields[name]
+		switch {
+		case !ok && forbiddenFields[name]:
+			errs = append(errs, apperror.FieldError{Field: name, Code: apperror.FieldForbidden, Message: name + " is set by the server"})
+		case !ok:
+			errs = append(errs, apperror.FieldError{Field: name, Code: apperror.FieldUnknown, Message: "unknown field " + name})
+		default:
+			field := target.Field(index)
+			value := reflect.New(field.Type())
+			if err := json.Unmarshal(raw[name], value.Interface()); err != nil {
+				errs = append(errs, apperror.FieldError{Field: name, Code: apperror.FieldType, Message: name + " must be " + typeName(field.Type())})
+				reported[name] = true
+				continue
+			}
+			field.Set(value.Elem())
+		}
+	}
+	for _, fieldErr := range validationErrors(req) {
+		if !reported[strings.Split(fieldErr.Field, "[")[0]] {
+			errs = append(errs, fieldErr)
+		}
+	}
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid body.", errs...)
+	}
+	return nil
+}
+
+// jsonFields maps the json names of a struct's fields to their index
+func jsonFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array of " + strings.TrimPrefix(strings.TrimPrefix(typeName(t.Elem()), "a "), "an ") + "s"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Float64:
+		return "a number"
+	}
+	return "an object"
+}
+
+// validationErrors runs the validate tags of req
+func validationErrors(req any) []apperror.FieldError {
+	err := validate.Struct(req)
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	errs := make([]apperror.FieldError, len(invalid))
+	for i, fe := range invalid {
+		// Namespace is Struct.field[0], drop the struct name
+		field := fe.Namespace()
+		if dot := strings.Index(field, "."); dot >= 0 {
+			field = field[dot+1:]
+		}
+		errs[i] = apperror.FieldError{Field: field, Code: fe.Tag(), Message: validationMessage(field, fe)}
+	}
+	return errs
+}
+
+func validationMessage(field string, fe validator.FieldError) string {
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Array
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "http_url":
+		return field + " must be an http or https URL"
+	case "min":
+		if isList && fe.Param() == "1" {
+			return field + " must not be empty"
+		}
+		if isList {
+			return fmt.Sprintf("%s must have at least %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+	case "max":
+		if isList {
+			return fmt.Sprintf("%s must have at most %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "tag":
+		return fmt.Sprintf("%s must be a tag of letters, digits and - . + # up to %d characters", field, maxTagLength)
+	}
+	return field + " is invalid"
+}
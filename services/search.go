@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/search"
+	"inkinkink111/go-blog-management/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary Search blogs
+// @Description Relevance-ranked full-text search over title, content and tags. Supports "quoted phrases", -excluded terms and -"excluded phrases".
+// @Tags blogs
+// @Accept json
+// @Produce json
+// @Param q query string true "Search query"
+// @Param page query string false "Page number" default(1)
+// @Param limit query string false "Items per page, capped by MAX_PAGE_LIMIT" default(10)
+// @Success 200 {object} models.SearchResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/search [get]
+// @Router /api/v2/search [get]
+func SearchBlogs(c *fiber.Ctx) error {
+	// Get query params
+	var errs []apperror.FieldError
+	query := search.ParseQuery(c.Query("q"))
+	pageInt, limitInt := parsePagination(c, &errs)
+	if query.IsEmpty() {
+		errs = append(errs, apperror.Invalid("q", "q must contain at least one search term"))
+	}
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	// Check for cache hit
+	cacheKey := utils.GenerateSearchCacheKey(query.Normalized(), pageInt, limitInt)
+	cachedResult := db.RedisClient.Get(context.Background(), cacheKey)
+	if (cachedResult.Err() == nil) && (cachedResult.Val() != "") {
+		var cachedResponse map[string]any
+		err := json.Unmarshal([]byte(cachedResult.Val()), &cachedResponse)
+		if err == nil {
+			return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+				Message: "Search blogs successfully.",
+				Data:    cachedResponse,
+			})
+		}
+	}
+	// Cache miss - run the search
+	var results []models.SearchResult
+	var totalCount int64
+	var err error
+	if search.Memory != nil {
+		results, totalCount, err = searchMemoryIndex(query, pageInt, limitInt)
+	} else {
+		results, totalCount, err = searchTextIndex(query, pageInt, limitInt)
+	}
+	if err != nil {
+		return err
+	}
+	// Prep resp data
+	respData := map[string]any{
+		"results":     results,
+		"query":       query.Raw,
+		"page":        pageInt,
+		"limit":       limitInt,
+		"total_pages": (totalCount + int64(limitInt) - 1) / int64(limitInt),
+		"total_item":  totalCount,
+	}
+	// Set cache
+	cacheValue, _ := json.Marshal(respData)
+	db.RedisClient.Set(context.Background(), cacheKey, cacheValue, 24*time.Hour)
+	// Send response
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Search blogs successfully.",
+		Data:    respData,
+	})
+}
+
+func searchTextIndex(query search.Query, page, limit int) ([]models.SearchResult, int64, error) {
+	blogRepo := repositories.NewBlogRepository()
+	// Search what was parsed, so results match the cache key
+	blogs, totalCount, err := blogRepo.SearchBlogs(query.Normalized(), page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	results := make([]models.SearchResult, len(blogs))
+	for i := range blogs {
+		results[i] = newSearchResult(&blogs[i].Blog, blogs[i].Score, query)
+	}
+	return results, totalCount, nil
+}
+
+func searchMemoryIndex(query search.Query, page, limit int) ([]models.SearchResult, int64, error) {
+	hits, totalCount := search.Memory.Search(query, (page-1)*limit, limit)
+	if len(hits) == 0 {
+		return []models.SearchResult{}, int64(totalCount), nil
+	}
+	blogIDs := make([]string, len(hits))
+	for i, hit := range hits {
+		blogIDs[i] = hit.BlogID
+	}
+	blogRepo := repositories.NewBlogRepository()
+	blogs, err := blogRepo.GetBlogsByIDs(blogIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]*models.Blog, len(blogs))
+	for i := range blogs {
+		byID[blogs[i].BlogID] = &blogs[i]
+	}
+	// Keep the index ranking, skip blogs deleted in the meantime
+	results := make([]models.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if blog, ok := byID[hit.BlogID]; ok {
+			results = append(results, newSearchResult(blog, hit.Score, query))
+		}
+	}
+	return results, int64(totalCount), nil
+}
+
+func newSearchResult(blog *models.Blog, score float64, query search.Query) models.SearchResult {
+	content := blog.Content
+	if blog.ContentHTML != "" {
+		content = utils.PlainText(blog.ContentHTML)
+	}
+	return models.SearchResult{
+		BlogSummary:    blog.Summary(),
+		Score:          score,
+		TitleHighlight: search.Highlight(blog.Title, query),
+		Snippet:        search.Snippet(content, query),
+	}
+}
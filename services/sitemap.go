@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/sitemap"
+	"inkinkink111/go-blog-management/utils"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Set while the entries below are complete. Writes keep them up to
+	// date, and they are rebuilt from MongoDB once it expires.
+	sitemapBuiltKey = "sitemap:built"
+	// Pages of the site ordered by member name, all scored 0: posts
+	// ("b:<blog_id>"), then tags ("t:<tag>"), then authors ("u:<user_id>")
+	sitemapURLsKey = "sitemap:urls"
+	// "<lastmod unix>|<path>" of each member
+	sitemapEntriesKey = "sitemap:entries"
+	sitemapIndexKey   = "sitemap:index"
+	// Held by the build in progress. Writes made meanwhile may be missing
+	// from what it read, so they wait in the pending hash, member to entry,
+	// and the build applies them when it completes.
+	sitemapBuildingKey = "sitemap:building"
+	sitemapPendingKey  = "sitemap:pending"
+	sitemapBuildTTL    = 5 * time.Minute
+	// Full rebuilds drop tags and authors left without posts
+	sitemapRebuildInterval = 24 * time.Hour
+	sitemapContentType     = "application/xml; charset=utf-8"
+)
+
+// sitemapPageKey caches the rendered sitemap of 0-based page
+func sitemapPageKey(page int64) string {
+	return fmt.Sprintf("sitemap:page:%d", page)
+}
+
+// Applies member/entry pairs to the sitemap, an empty entry removing the
+// member, and returns the lowest rank whose page changed, -1 if none. A
+// sitemap being built gets them once the build completes, one not built
+// yet is left alone, its build will read the change.
+var sitemapUpdate = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	if redis.call("EXISTS", KEYS[4]) == 1 then
+		for i = 1, #ARGV, 2 do
+			redis.call("HSET", KEYS[5], ARGV[i], ARGV[i + 1])
+		end
+	end
+	return -1
+end
+local first = -1
+for i = 1, #ARGV, 2 do
+	local member, entry = ARGV[i], ARGV[i + 1]
+	local rank = redis.call("ZRANK", KEYS[2], member)
+	if entry == "" then
+		if rank then
+			redis.call("ZREM", KEYS[2], member)
+			redis.call("HDEL", KEYS[3], member)
+		end
+	elseif redis.call("HGET", KEYS[3], member) ~= entry then
+		if not rank then
+			redis.call("ZADD", KEYS[2], 0, member)
+			rank = redis.call("ZRANK", KEYS[2], member)
+		end
+		redis.call("HSET", KEYS[3], member, entry)
+	else
+		rank = false
+	end
+	if rank and (first == -1 or rank < first) then
+		first = rank
+	end
+end
+return first
+`)
+
+// Completes the build holding ARGV[1]: applies the writes made while it
+// ran and marks the sitemap built for ARGV[2] seconds. Returns 0 when the
+// build was cancelled by a rebuild, whose own build will run instead.
+var sitemapComplete = redis.NewScript(`
+if redis.call("GET", KEYS[4]) ~= ARGV[1] then
+	return 0
+end
+local pending = redis.call("HGETALL", KEYS[5])
+for i = 1, #pending, 2 do
+	local member, entry = pending[i], pending[i + 1]
+	if entry == "" then
+		redis.call("ZREM", KEYS[2], member)
+		redis.call("HDEL", KEYS[3], member)
+	else
+		redis.call("ZADD", KEYS[2], 0, member)
+		redis.call("HSET", KEYS[3], member, entry)
+	end
+end
+redis.call("DEL", KEYS[4], KEYS[5])
+redis.call("SET", KEYS[1], 1, "EX", ARGV[2])
+return 1
+`)
+
+// Keys of the sitemap scripts
+var sitemapKeys = []string{sitemapBuiltKey, sitemapURLsKey, sitemapEntriesKey, sitemapBuildingKey, sitemapPendingKey}
+
+func sitemapEntry(path string, lastMod time.Time) string {
+	return fmt.Sprintf("%d|%s", lastMod.Unix(), path)
+}
+
+// GetSitemap serves the sitemap of published posts, tags and authors.
+// Past sitemap.MaxURLs URLs it serves an index of /sitemaps/<page>.xml.
+func GetSitemap(c *fiber.Ctx) error {
+	count, err := sitemapSize()
+	if err != nil {
+		return err
+	}
+	if count <= sitemap.MaxURLs {
+		return serveSitemapPage(c, 0)
+	}
+	ctx := context.Background()
+	body, err := db.RedisClient.Get(ctx, sitemapIndexKey).Bytes()
+	if err != nil {
+		pages := make([]sitemap.URL, sitemapPages(count))
+		for i := range pages {
+			pages[i] = sitemap.URL{Loc: fmt.Sprintf("%s/sitemaps/%d.xml", config.SiteURL(), i+1)}
+		}
+		if body, err = sitemap.Index(pages); err != nil {
+			return err
+		}
+		db.RedisClient.Set(ctx, sitemapIndexKey, body, sitemapRebuildInterval)
+	}
+	c.Set(fiber.HeaderContentType, sitemapContentType)
+	return c.Status(fiber.StatusOK).Send(body)
+}
+
+// GetSitemapPage serves one of the sitemaps of the index, from 1
+func GetSitemapPage(c *fiber.Ctx) error {
+	count, err := sitemapSize()
+	if err != nil {
+		return err
+	}
+	page, err := strconv.ParseInt(c.Params("page"), 10, 64)
+	if err != nil || page < 1 || page > max(sitemapPages(count), 1) {
+		return apperror.NotFound(apperror.CodeNotFound, "Sitemap not found.")
+	}
+	return serveSitemapPage(c, page-1)
+}
+
+func sitemapPages(count int64) int64 {
+	return (count + sitemap.MaxURLs - 1) / sitemap.MaxURLs
+}
+
+// serveSitemapPage sends a cached page of the sitemap, rendering it from
+// the entries when it was dropped
+func serveSitemapPage(c *fiber.Ctx, page int64) error {
+	ctx := context.Background()
+	body, err := db.RedisClient.Get(ctx, sitemapPageKey(page)).Bytes()
+	if err != nil {
+		start := page * sitemap.MaxURLs
+		members, err := db.RedisClient.ZRange(ctx, sitemapURLsKey, start, start+sitemap.MaxURLs-1).Result()
+		if err != nil {
+			return err
+		}
+		urls := []sitemap.URL{}
+		if len(members) > 0 {
+			entries, err := db.RedisClient.HMGet(ctx, sitemapEntriesKey, members...).Result()
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				value, _ := entry.(string)
+				unix, path, ok := strings.Cut(value, "|")
+				if !ok {
+					continue
+				}
+				seconds, _ := strconv.ParseInt(unix, 10, 64)
+				urls = append(urls, sitemap.URL{Loc: config.SiteURL() + path, LastMod: time.Unix(seconds, 0)})
+			}
+		}
+		if body, err = sitemap.URLSet(urls); err != nil {
+			return err
+		}
+		db.RedisClient.Set(ctx, sitemapPageKey(page), body, sitemapRebuildInterval)
+	}
+	c.Set(fiber.HeaderContentType, sitemapContentType)
+	return c.Status(fiber.StatusOK).Send(body)
+}
+
+// sitemapSize returns the number of URLs in the sitemap, building it
+// first when it expired
+func sitemapSize() (int64, error) {
+	ctx := context.Background()
+	built, err := db.RedisClient.Exists(ctx, sitemapBuiltKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if built == 0 {
+		if err := buildSitemap(); err != nil {
+			return 0, err
+		}
+	}
+	return db.RedisClient.ZCard(ctx, sitemapURLsKey).Result()
+}
+
+// buildSitemap lists every published post, and the tags and authors that
+// have one, with the latest update of their posts. While another build
+// runs the current entries are served as they are.
+func buildSitemap() error {
+	ctx := context.Background()
+	token := utils.GenerateSecret()
+	locked, err := db.RedisClient.SetNX(ctx, sitemapBuildingKey, token, sitemapBuildTTL).Result()
+	if err != nil || !locked {
+		return err
+	}
+	// Writes made before now are in what is read below
+	db.RedisClient.Del(ctx, sitemapPendingKey)
+	blogs, err := repositories.NewBlogRepository().ListSitemapBlogs()
+	if err != nil {
+		return err
+	}
+	entries := map[string]string{}
+	lastMods := map[string]time.Time{}
+	paths := map[string]string{}
+	for i := range blogs {
+		blog := &blogs[i]
+		entries["b:"+blog.BlogID] = sitemapEntry(blogPath(blog), blog.UpdatedAt)
+		for _, tag := range blog.Tags {
+			paths["t:"+tag] = tagPath(tag)
+			if blog.UpdatedAt.After(lastMods["t:"+tag]) {
+				lastMods["t:"+tag] = blog.UpdatedAt
+			}
+		}
+		if blog.AuthorID != models.DeletedUserID {
+			paths["u:"+blog.AuthorID] = authorPath(blog.AuthorID)
+			if blog.UpdatedAt.After(lastMods["u:"+blog.AuthorID]) {
+				lastMods["u:"+blog.AuthorID] = blog.UpdatedAt
+			}
+		}
+	}
+	for member, path := range paths {
+		entries[member] = sitemapEntry(path, lastMods[member])
+	}
+	pipe := db.RedisClient.TxPipeline()
+	pipe.Del(ctx, sitemapURLsKey, sitemapEntriesKey)
+	members := make([]redis.Z, 0, len(entries))
+	values := make([]any, 0, 2*len(entries))
+	for member, entry := range entries {
+		members = append(members, redis.Z{Member: member})
+		values = append(values, member, entry)
+	}
+	if len(members) > 0 {
+		pipe.ZAdd(ctx, sitemapURLsKey, members...)
+		pipe.HSet(ctx, sitemapEntriesKey, values...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if err := sitemapComplete.Run(ctx, db.RedisClient, sitemapKeys, token, int(sitemapRebuildInterval.Seconds())).Err(); err != nil {
+		return err
+	}
+	dropSitemapPages(0)
+	return nil
+}
+
+// updateSitemap brings the sitemap up to date after a write on blog. The
+// tag and author pages it was or is now listed on changed at its last
+// update. Failures are logged and fixed by the next rebuild.
+func updateSitemap(blog *models.Blog, wasPublished bool, previousTags []string) {
+	if !wasPublished && !blog.Published() {
+		return
+	}
+	args := []any{"b:" + blog.BlogID, ""}
+	var tags []string
+	if wasPublished {
+		tags = append(tags, previousTags...)
+	}
+	if blog.Published() {
+		args[1] = sitemapEntry(blogPath(blog), blog.UpdatedAt)
+		tags = append(tags, blog.Tags...)
+	}
+	slices.Sort(tags)
+	for _, tag := range slices.Compact(tags) {
+		args = append(args, "t:"+tag, sitemapEntry(tagPath(tag), blog.UpdatedAt))
+	}
+	if blog.AuthorID != models.DeletedUserID {
+		args = append(args, "u:"+blog.AuthorID, sitemapEntry(authorPath(blog.AuthorID), blog.UpdatedAt))
+	}
+	first, err := sitemapUpdate.Run(context.Background(), db.RedisClient, sitemapKeys, args...).Int64()
+	if err != nil {
+		log.Printf("Failed to update the sitemap for blog %s: %v", blog.BlogID, err)
+		return
+	}
+	if first >= 0 {
+		dropSitemapPages(first / sitemap.MaxURLs)
+	}
+}
+
+// removeFromSitemap drops deleted blogs from the sitemap
+func removeFromSitemap(blogIDs []string) {
+	args := make([]any, 0, 2*len(blogIDs))
+	for _, blogID := range blogIDs {
+		args = append(args, "b:"+blogID, "")
+	}
+	first, err := sitemapUpdate.Run(context.Background(), db.RedisClient, sitemapKeys, args...).Int64()
+	if err != nil {
+		log.Printf("Failed to remove blogs from the sitemap: %v", err)
+		return
+	}
+	if first >= 0 {
+		dropSitemapPages(first / sitemap.MaxURLs)
+	}
+}
+
+// dropSitemapPages drops the rendered sitemap pages from page on, whose
+// URLs moved, and the index
+func dropSitemapPages(from int64) {
+	ctx := context.Background()
+	keys := []string{sitemapIndexKey}
+	pageKeys, _ := db.RedisClient.Keys(ctx, "sitemap:page:*").Result()
+	for _, key := range pageKeys {
+		page, err := strconv.ParseInt(strings.TrimPrefix(key, "sitemap:page:"), 10, 64)
+		if err == nil && page >= from {
+			keys = append(keys, key)
+		}
+	}
+	db.RedisClient.Del(ctx, keys...)
+}
+
+// rebuildSitemap has the sitemap rebuilt on its next read, after changes
+// too wide to apply one by one. A build in progress may have read the
+// data before them, it is cancelled.
+func rebuildSitemap() {
+	db.RedisClient.Del(context.Background(), sitemapBuiltKey, sitemapBuildingKey)
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/syndication"
+	"inkinkink111/go-blog-management/utils"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Posts in each feed, newest first
+const feedItems = 20
+
+type feedFormat struct {
+	name        string
+	file        string
+	contentType string
+	render      func(syndication.Feed) ([]byte, error)
+}
+
+var (
+	rssFormat  = feedFormat{"rss", "feed.xml", "application/rss+xml; charset=utf-8", syndication.RSS}
+	atomFormat = feedFormat{"atom", "atom.xml", "application/atom+xml; charset=utf-8", syndication.Atom}
+	jsonFormat = feedFormat{"json", "feed.json", "application/feed+json; charset=utf-8", syndication.JSONFeed}
+)
+
+// renderedFeed is a feed as cached, with the validators of its body
+type renderedFeed struct {
+	Body         []byte    `json:"body"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Paths of the public pages of a blog, a tag and an author, relative to
+// the site URL
+func blogPath(blog *models.Blog) string {
+	return fmt.Sprintf("/blogs/%s/%s", blog.BlogID, blog.Slug)
+}
+
+func tagPath(tag string) string {
+	return "/tags/" + url.PathEscape(tag)
+}
+
+func authorPath(userID string) string {
+	return "/users/" + userID
+}
+
+// RSSFeed serves the latest posts as RSS 2.0, all of them or those of the
+// tag or author in the path.
+func RSSFeed(c *fiber.Ctx) error {
+	return serveFeed(c, rssFormat)
+}
+
+// AtomFeed serves the latest posts as Atom 1.0
+func AtomFeed(c *fiber.Ctx) error {
+	return serveFeed(c, atomFormat)
+}
+
+// JSONFeed serves the latest posts as JSON Feed 1.1
+func JSONFeed(c *fiber.Ctx) error {
+	return serveFeed(c, jsonFormat)
+}
+
+func serveFeed(c *fiber.Ctx, format feedFormat) error {
+	query := models.BlogListQuery{
+		Page:        1,
+		Limit:       feedItems,
+		TagMode:     models.TagModeAny,
+		Sort:        models.SortNewest,
+		WithContent: true,
+	}
+	// Pages are named after the canonical scope, not the requested path,
+	// which may use a tag alias
+	title, pagePath, scope := config.SiteName(), "", "all"
+	if c.Params("name") != "" {
+		tag, err := canonicalTagParam(c)
+		if err != nil {
+			return err
+		}
+		query.Tags = []string{tag}
+		title = fmt.Sprintf("%s: #%s", title, tag)
+		pagePath = tagPath(tag)
+		scope = "tag:" + tag
+	}
+	if userID := c.Params("user_id"); userID != "" {
+		user, err := repositories.NewUserRepository().GetUserByUserID(userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return apperror.NotFound(apperror.CodeUserNotFound, "User not found.")
+		}
+		query.AuthorID = user.UserId
+		title = fmt.Sprintf("%s: %s", title, user.AuthorSummary().DisplayName)
+		pagePath = authorPath(user.UserId)
+		scope = "author:" + user.UserId
+	}
+	// Under blog:list: so blog writes drop it with the lists
+	cacheKey := fmt.Sprintf("blog:list:feed:%s:%s", format.name, scope)
+	var feed renderedFeed
+	cached, err := db.RedisClient.Get(context.Background(), cacheKey).Result()
+	if err != nil || json.Unmarshal([]byte(cached), &feed) != nil {
+		if feed, err = renderFeed(query, format, syndication.Feed{
+			Title:       title,
+			Description: fmt.Sprintf("Latest posts of %s", title),
+			HomeURL:     config.SiteURL() + pagePath,
+			FeedURL:     config.SiteURL() + pagePath + "/" + format.file,
+		}); err != nil {
+			return err
+		}
+		cacheValue, _ := json.Marshal(feed)
+		db.RedisClient.Set(context.Background(), cacheKey, cacheValue, 7*24*time.Hour)
+	}
+	c.Set(fiber.HeaderETag, feed.ETag)
+	if !feed.LastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, feed.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if feedNotModified(c, feed) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set(fiber.HeaderContentType, format.contentType)
+	return c.Status(fiber.StatusOK).Send(feed.Body)
+}
+
+// renderFeed fills feed with the blogs of query and renders it
+func renderFeed(query models.BlogListQuery, format feedFormat, feed syndication.Feed) (renderedFeed, error) {
+	blogs, _, _, err := repositories.NewBlogRepository().GetAllBlogs(query)
+	if err != nil {
+		return renderedFeed{}, err
+	}
+	authorIDs := make([]string, len(blogs))
+	for i := range blogs {
+		authorIDs[i] = blogs[i].AuthorID
+	}
+	authors, err := loadAuthors(authorIDs)
+	if err != nil {
+		return renderedFeed{}, err
+	}
+	feed.Items = make([]syndication.Item, len(blogs))
+	for i := range blogs {
+		blog := &blogs[i]
+		item := syndication.Item{
+			// Unlike the URL, the id survives title changes
+			ID:          fmt.Sprintf("%s/blogs/%s", config.SiteURL(), blog.BlogID),
+			URL:         config.SiteURL() + blogPath(blog),
+			Title:       blog.Title,
+			Summary:     blog.Excerpt,
+			ContentHTML: blog.ContentHTML,
+			Tags:        blog.Tags,
+			Published:   blog.CreatedAt,
+			Updated:     blog.UpdatedAt,
+		}
+		if author, ok := authors[blog.AuthorID]; ok {
+			item.Author = author.DisplayName
+		}
+		if blog.UpdatedAt.After(feed.Updated) {
+			feed.Updated = blog.UpdatedAt
+		}
+		feed.Items[i] = item
+	}
+	body, err := format.render(feed)
+	if err != nil {
+		return renderedFeed{}, err
+	}
+	sum := sha256.Sum256(body)
+	return renderedFeed{
+		Body:         body,
+		ETag:         fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16])),
+		LastModified: feed.Updated.Truncate(time.Second),
+	}, nil
+}
+
+// feedNotModified reports whether the client's copy of feed is current.
+// If-Modified-Since is only used without If-None-Match, since removing a
+// post changes the feed without changing its last modification.
+func feedNotModified(c *fiber.Ctx, feed renderedFeed) bool {
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		return utils.MatchETag(ifNoneMatch, feed.ETag)
+	}
+	since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	return err == nil && !feed.LastModified.IsZero() && !feed.LastModified.After(since)
+}
@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/search"
+	"inkinkink111/go-blog-management/utils"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const tagsCacheKey = "blog:tags:all"
+
+// @Summary Get all tags
+// @Description List tags with their post counts, descriptions and aliases
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param sort query string false "Sort order" Enums(count, name) default(count)
+// @Success 200 {object} models.GetTagsResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/tags [get]
+// @Router /api/v2/tags [get]
+func GetTags(c *fiber.Ctx) error {
+	sortBy := c.Query("sort", "count")
+	if sortBy != "count" && sortBy != "name" {
+		return apperror.Validation("Invalid query params.", apperror.Invalid("sort", "sort must be one of count, name"))
+	}
+	tags, err := loadTags()
+	if err != nil {
+		return err
+	}
+	if sortBy == "name" {
+		sort.SliceStable(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get tags successfully.",
+		Data:    tags,
+	})
+}
+
+// @Summary Autocomplete tags
+// @Description Tags whose name or alias starts with the prefix, most used first
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param prefix query string true "Tag prefix"
+// @Param limit query int false "Max suggestions" default(10)
+// @Success 200 {object} models.GetTagsResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/tags/autocomplete [get]
+// @Router /api/v2/tags/autocomplete [get]
+func AutocompleteTags(c *fiber.Ctx) error {
+	prefix := utils.NormalizeTag(c.Query("prefix"))
+	limit := c.QueryInt("limit", 10)
+	if prefix == "" {
+		return apperror.Validation("Invalid query params.", apperror.Required("prefix"))
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	tags, err := loadTags()
+	if err != nil {
+		return err
+	}
+	suggestions := []models.Tag{}
+	for _, tag := range tags {
+		matches := strings.HasPrefix(tag.Name, prefix) || slices.ContainsFunc(tag.Aliases, func(alias string) bool {
+			return strings.HasPrefix(alias, prefix)
+		})
+		if matches {
+			suggestions = append(suggestions, tag)
+		}
+		if len(suggestions) == limit {
+			break
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Autocomplete tags successfully.",
+		Data:    suggestions,
+	})
+}
+
+// @Summary Create or update a tag
+// @Description Set a tag's description and aliases. Blogs using one of the aliases are retagged with the canonical name.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Canonical tag name"
+// @Param tag body models.UpsertTagRequest true "Tag data"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/tags/{name} [put]
+// @Router /api/v2/tags/{name} [put]
+func UpsertTag(c *fiber.Ctx) error {
+	name := utils.NormalizeTag(c.Params("name"))
+	body := &models.UpsertTagRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	if validate.Var(name, "required,tag") != nil {
+		return apperror.Validation("Invalid tag name.", apperror.Invalid("name", "name must be a tag of letters, digits and - . + #"))
+	}
+	aliases := slices.DeleteFunc(utils.NormalizeTags(body.Aliases), func(alias string) bool {
+		return alias == name
+	})
+	tagRepo := repositories.NewTagRepository()
+	// Aliases must not belong to another tag
+	for _, candidate := range append([]string{name}, aliases...) {
+		existing, err := tagRepo.GetTag(candidate)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.Name != name {
+			return apperror.Conflict(apperror.CodeTagConflict, fmt.Sprintf("%s is already used by tag %s.", candidate, existing.Name))
+		}
+	}
+	now := time.Now()
+	tag := &models.Tag{
+		Name:        name,
+		Description: body.Description,
+		Aliases:     aliases,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := tagRepo.UpsertTag(tag); err != nil {
+		return err
+	}
+	// Canonicalize blogs already using an alias
+	for _, alias := range aliases {
+		if err := rewriteTag(alias, name); err != nil {
+			return err
+		}
+	}
+	invalidateListCaches()
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: "Tag updated successfully.",
+	})
+}
+
+// @Summary Rename a tag
+// @Description Rename a tag and rewrite every blog using it. The old name is kept as an alias.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Current tag name"
+// @Param tag body models.RenameTagRequest true "New name"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/tags/{name}/rename [post]
+// @Router /api/v2/tags/{name}/rename [post]
+func RenameTag(c *fiber.Ctx) error {
+	from := utils.NormalizeTag(c.Params("name"))
+	body := &models.RenameTagRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	to := utils.NormalizeTag(body.Name)
+	if from == "" || to == "" || from == to {
+		return apperror.Validation("New name must be set and differ from the current name.", apperror.Invalid("name", "name must be set and differ from the current name"))
+	}
+	// Renaming onto an existing tag is a merge
+	tagRepo := repositories.NewTagRepository()
+	existing, err := tagRepo.GetTag(to)
+	if err != nil {
+		return err
+	}
+	counts, err := repositories.NewBlogRepository().CountTags()
+	if err != nil {
+		return err
+	}
+	if existing != nil || counts[to] > 0 {
+		return apperror.Conflict(apperror.CodeTagConflict, "Tag already exists, merge the tags instead.")
+	}
+	return moveTag(c, from, to, "Tag renamed successfully.")
+}
+
+// @Summary Merge a tag into another
+// @Description Retag every blog using the tag with the target tag and keep the merged name as an alias of the target
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Tag to merge"
+// @Param tag body models.RenameTagRequest true "Target tag"
+// @Success 200 {object} models.ResponseMsg
+// @Failure 400 {object} apperror.Problem
+// @Failure 403 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/tags/{name}/merge [post]
+// @Router /api/v2/tags/{name}/merge [post]
+func MergeTag(c *fiber.Ctx) error {
+	from := utils.NormalizeTag(c.Params("name"))
+	body := &models.RenameTagRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
+	}
+	to := utils.NormalizeTag(body.Name)
+	// Merging into an alias means merging into its tag
+	resolved, err := repositories.NewTagRepository().ResolveAliases([]string{to})
+	if err != nil {
+		return err
+	}
+	if canonical, ok := resolved[to]; ok {
+		to = canonical
+	}
+	if from == "" || to == "" || from == to {
+		return apperror.Validation("Target tag must be set and differ from the merged tag.", apperror.Invalid("name", "name must be set and differ from the merged tag"))
+	}
+	return moveTag(c, from, to, "Tag merged successfully.")
+}
+
+// moveTag folds tag from, with its aliases, into tag to and retags blogs
+func moveTag(c *fiber.Ctx, from, to, message string) error {
+	tagRepo := repositories.NewTagRepository()
+	source, err := tagRepo.GetTag(from)
+	if err == nil && source != nil && source.Name != from {
+		return apperror.BadRequest(apperror.CodeTagIsAlias, fmt.Sprintf("%s is an alias of tag %s.", from, source.Name))
+	}
+	// A tag exists through its record or the blogs using it
+	if err == nil && source == nil {
+		var used bool
+		if used, err = repositories.NewBlogRepository().HasTag(from); err == nil && !used {
+			return apperror.NotFound(apperror.CodeTagNotFound, fmt.Sprintf("Tag %s not found.", from))
+		}
+	}
+	var target *models.Tag
+	if err == nil {
+		target, err = tagRepo.GetTag(to)
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if target == nil {
+		target = &models.Tag{Name: to, CreatedAt: now}
+	}
+	target.UpdatedAt = now
+	target.Aliases = append(target.Aliases, from)
+	if source != nil {
+		target.Aliases = append(target.Aliases, source.Aliases...)
+		if target.Description == "" {
+			target.Description = source.Description
+		}
+		if err := tagRepo.DeleteTag(source.Name); err != nil {
+			return err
+		}
+	}
+	target.Aliases = slices.DeleteFunc(utils.NormalizeTags(target.Aliases), func(alias string) bool {
+		return alias == to
+	})
+	if err := tagRepo.UpsertTag(target); err != nil {
+		return err
+	}
+	if err := rewriteTag(from, to); err != nil {
+		return err
+	}
+	if err := repositories.NewFollowRepository().MoveTagFollows(from, to); err != nil {
+		return err
+	}
+	dropAllRelated()
+	rebuildSitemap()
+	invalidateListCaches()
+	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+		Message: message,
+	})
+}
+
+// rewriteTag retags blogs and drops their cached copies
+func rewriteTag(from, to string) error {
+	blogRepo := repositories.NewBlogRepository()
+	blogIDs, err := blogRepo.ReplaceTag(from, to)
+	if err != nil || len(blogIDs) == 0 {
+		return err
+	}
+	cacheKeys := make([]string, len(blogIDs))
+	for i, blogID := range blogIDs {
+		cacheKeys[i] = fmt.Sprintf("blog:post:%s", blogID)
+	}
+	db.RedisClient.Del(context.Background(), cacheKeys...)
+	if search.Memory != nil {
+		blogs, err := blogRepo.GetBlogsByIDs(blogIDs)
+		if err != nil {
+			return err
+		}
+		for i := range blogs {
+			search.IndexBlog(&blogs[i])
+		}
+	}
+	return nil
+}
+
+// canonicalizeTags replaces aliases with their canonical tag names
+func canonicalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return tags, nil
+	}
+	resolved, err := repositories.NewTagRepository().ResolveAliases(tags)
+	if err != nil {
+		return nil, err
+	}
+	canonical := make([]string, len(tags))
+	for i, tag := range tags {
+		if name, ok := resolved[tag]; ok {
+			tag = name
+		}
+		canonical[i] = tag
+	}
+	return utils.NormalizeTags(canonical), nil
+}
+
+// loadTags merges tag metadata with post counts, most used first
+func loadTags() ([]models.Tag, error) {
+	cachedResult := db.RedisClient.Get(context.Background(), tagsCacheKey)
+	if (cachedResult.Err() == nil) && (cachedResult.Val() != "") {
+		var tags []models.Tag
+		if err := json.Unmarshal([]byte(cachedResult.Val()), &tags); err == nil {
+			return tags, nil
+		}
+	}
+	counts, err := repositories.NewBlogRepository().CountTags()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := repositories.NewTagRepository().ListTags()
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]models.Tag, 0, len(counts))
+	for _, tag := range stored {
+		tag.PostCount = counts[tag.Name]
+		delete(counts, tag.Name)
+		tags = append(tags, tag)
+	}
+	// Free-form tags without metadata
+	for name, count := range counts {
+		tags = append(tags, models.Tag{Name: name, Aliases: []string{}, PostCount: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	cacheValue, _ := json.Marshal(tags)
+	db.RedisClient.Set(context.Background(), tagsCacheKey, cacheValue, 24*time.Hour)
+	return tags, nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/utils"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Weight of each kind of activity in trending scores
+const (
+	trendingViewWeight     = 1
+	trendingReactionWeight = 3
+	trendingCommentWeight  = 5
+)
+
+// How long a computed ranking is served before it is summed again
+const trendingCacheTTL = 5 * time.Minute
+
+// trendingBuckets are sorted sets of blog activity per hour or per day,
+// overall and per tag, incremented as activity happens
+type trendingBuckets struct {
+	name   string
+	layout string
+	span   time.Duration
+	ttl    time.Duration
+}
+
+var (
+	hourlyBuckets = trendingBuckets{"hour", "2006010215", time.Hour, 25 * time.Hour}
+	dailyBuckets  = trendingBuckets{"day", "20060102", 24 * time.Hour, 31 * 24 * time.Hour}
+)
+
+func (tb trendingBuckets) key(at time.Time, tag string) string {
+	key := fmt.Sprintf("blog:trending:%s:%s", tb.name, at.UTC().Format(tb.layout))
+	if tag != "" {
+		key += ":" + tag
+	}
+	return key
+}
+
+// A window sums its buckets, halving the weight of activity every halfLife
+type trendingWindow struct {
+	buckets  trendingBuckets
+	count    int
+	halfLife time.Duration
+}
+
+var trendingWindows = map[string]trendingWindow{
+	models.TrendingDay:   {hourlyBuckets, 24, 6 * time.Hour},
+	models.TrendingWeek:  {dailyBuckets, 7, 2 * 24 * time.Hour},
+	models.TrendingMonth: {dailyBuckets, 30, 7 * 24 * time.Hour},
+}
+
+// addTrendingActivity queues weighted activity on a blog into the current
+// buckets of pipe, overall and for each of its tags
+func addTrendingActivity(pipe redis.Pipeliner, blogID string, tags []string, weight float64) {
+	ctx := context.Background()
+	now := time.Now()
+	for _, buckets := range []trendingBuckets{hourlyBuckets, dailyBuckets} {
+		for _, tag := range append([]string{""}, tags...) {
+			key := buckets.key(now, tag)
+			pipe.ZIncrBy(ctx, key, weight, blogID)
+			pipe.Expire(ctx, key, buckets.ttl)
+		}
+	}
+}
+
+// trackActivity records activity on a blog. Rankings are best effort, a
+// failure is logged rather than failing the request.
+func trackActivity(blog *models.Blog, weight float64) {
+	pipe := db.RedisClient.Pipeline()
+	addTrendingActivity(pipe, blog.BlogID, blog.Tags, weight)
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		log.Printf("Failed to track activity on blog %s: %v", blog.BlogID, err)
+	}
+}
+
+// @Summary Get trending blogs
+// @Description Blogs ranked by recent views, reactions and comments, older activity weighing less. Without enough activity the newest blogs fill the list with a score of 0.
+// @Tags blogs
+// @Accept json
+// @Produce json
+// @Param window query string false "Activity window" Enums(24h, 7d, 30d) default(24h)
+// @Param tag query string false "Only blogs with this tag"
+// @Param limit query string false "Number of blogs, capped by MAX_PAGE_LIMIT" default(10)
+// @Success 200 {object} models.GetTrendingResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/blogs/trending [get]
+// @Router /api/v2/blogs/trending [get]
+func GetTrendingBlogs(c *fiber.Ctx) error {
+	// Get query params
+	var errs []apperror.FieldError
+	_, limit := parsePagination(c, &errs)
+	windowName := c.Query("window", models.TrendingDay)
+	window, ok := trendingWindows[windowName]
+	if !ok {
+		errs = append(errs, apperror.Invalid("window", "window must be one of "+strings.Join(models.TrendingWindows, ", ")))
+	}
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	var tags []string
+	tag := utils.NormalizeTag(c.Query("tag", ""))
+	if tag != "" {
+		var err error
+		if tags, err = canonicalizeTags([]string{tag}); err != nil {
+			return err
+		}
+		tag = tags[0]
+	}
+	key, err := trendingRanking(windowName, window, tag)
+	if err != nil {
+		return err
+	}
+	// Fetch extra to make up for drafts and deleted blogs
+	entries, err := db.RedisClient.ZRevRangeWithScores(context.Background(), key, 0, int64(2*limit-1)).Result()
+	if err != nil {
+		return err
+	}
+	blogs, err := trendingBlogs(entries, limit)
+	if err != nil {
+		return err
+	}
+	if len(blogs) < limit {
+		if blogs, err = fillWithRecent(blogs, tags, limit); err != nil {
+			return err
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get trending blogs successfully.",
+		Data: map[string]any{
+			"blogs":  blogs,
+			"window": windowName,
+			"tag":    tag,
+			"limit":  limit,
+		},
+	})
+}
+
+// trendingRanking returns the key of a window's ranking, summing its
+// decayed buckets when the cached one expired
+func trendingRanking(windowName string, window trendingWindow, tag string) (string, error) {
+	ctx := context.Background()
+	key := fmt.Sprintf("blog:trending:top:%s:%s", windowName, tag)
+	exists, err := db.RedisClient.Exists(ctx, key).Result()
+	if err != nil || exists > 0 {
+		return key, err
+	}
+	now := time.Now()
+	keys := make([]string, window.count)
+	weights := make([]float64, window.count)
+	for age := range window.count {
+		keys[age] = window.buckets.key(now.Add(-time.Duration(age)*window.buckets.span), tag)
+		weights[age] = math.Pow(0.5, float64(time.Duration(age)*window.buckets.span)/float64(window.halfLife))
+	}
+	pipe := db.RedisClient.TxPipeline()
+	pipe.ZUnionStore(ctx, key, &redis.ZStore{Keys: keys, Weights: weights, Aggregate: "SUM"})
+	pipe.Expire(ctx, key, trendingCacheTTL)
+	_, err = pipe.Exec(ctx)
+	return key, err
+}
+
+// trendingBlogs loads ranked blogs in order, skipping deleted blogs,
+// drafts and blogs whose activity cancelled out
+func trendingBlogs(entries []redis.Z, limit int) ([]models.TrendingBlog, error) {
+	blogs := []models.TrendingBlog{}
+	if len(entries) == 0 {
+		return blogs, nil
+	}
+	blogIDs := make([]string, len(entries))
+	for i, entry := range entries {
+		blogIDs[i] = entry.Member.(string)
+	}
+	found, err := repositories.NewBlogRepository().GetBlogSummariesByIDs(blogIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Blog, len(found))
+	for i := range found {
+		byID[found[i].BlogID] = &found[i]
+	}
+	for _, entry := range entries {
+		blog, ok := byID[entry.Member.(string)]
+		if !ok || !blog.Published() || entry.Score <= 0 {
+			continue
+		}
+		blogs = append(blogs, models.TrendingBlog{BlogSummary: blog.Summary(), TrendingScore: entry.Score})
+		if len(blogs) == limit {
+			break
+		}
+	}
+	return blogs, nil
+}
+
+// fillWithRecent tops blogs up to limit with the newest blogs not in it
+func fillWithRecent(blogs []models.TrendingBlog, tags []string, limit int) ([]models.TrendingBlog, error) {
+	recent, _, _, err := repositories.NewBlogRepository().GetAllBlogs(models.BlogListQuery{
+		Page:    1,
+		Limit:   limit,
+		Tags:    tags,
+		TagMode: models.TagModeAny,
+		Sort:    models.SortNewest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range recent {
+		if len(blogs) == limit {
+			break
+		}
+		listed := slices.ContainsFunc(blogs, func(blog models.TrendingBlog) bool {
+			return blog.BlogID == recent[i].BlogID
+		})
+		if !listed {
+			blogs = append(blogs, models.TrendingBlog{BlogSummary: recent[i].Summary()})
+		}
+	}
+	return blogs, nil
+}
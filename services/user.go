@@ -3,6 +3,7 @@ package services
 import (
 	"time"
 
+	"inkinkink111/go-blog-management/apperror"
 	"inkinkink111/go-blog-management/models"
 	"inkinkink111/go-blog-management/repositories"
 	"inkinkink111/go-blog-management/utils"
@@ -15,63 +16,49 @@ import (
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param register body object{email=string,password=string,name=string} true "User data"
-// @Success 200 {object} models.ResponseMsg
-// @Failure 400 {object} models.ResponseError
-// @Failure 409 {object} models.ResponseMsg
-// @Failure 500 {object} models.ResponseError
+// @Param register body models.RegisterRequest true "User data"
+// @Success 201 {object} models.ResponseMsg "Created, v2"
+// @Success 200 {object} models.ResponseMsg "Created, v1"
+// @Failure 400 {object} apperror.Problem
+// @Failure 409 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/v1/register [post]
+// @Router /api/v2/users [post]
 func Register(c *fiber.Ctx) error {
-	// Extract body
-	body := &models.User{}
-	if err := c.BodyParser(body); err != nil {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseError{
-			Message: "Invalid body.",
-			Error:   err.Error(),
-		})
-	}
-	// Validate
-	if (body.Email == "") || (body.Password == "") || (body.Name == "") {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseError{
-			Message: "Invalid body.",
-			Error:   "Missing required fields.",
-		})
+	// Extract and validate body
+	body := &models.RegisterRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
 	}
 	// Check if user already exists
 	userRepo := repositories.NewUserRepository()
 	existingUser, err := userRepo.GetUserByEmail(body.Email)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Internal server error.",
-			Error:   err.Error(),
-		})
+		return err
 	}
 	if existingUser != nil {
-		return c.Status(fiber.ErrConflict.Code).JSON(models.ResponseMsg{
-			Message: "User already exists",
-		})
+		return apperror.Conflict(apperror.CodeUserExists, "User already exists.")
 	}
 	// Hash password
 	hashedPassword, err := utils.HashPassword(body.Password)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Internal server error.",
-			Error:   err.Error(),
-		})
+		return err
+	}
+	// Store in Mongo, roles are granted by admins, never at sign up
+	user := &models.User{
+		Email:     body.Email,
+		Password:  hashedPassword,
+		Name:      body.Name,
+		CreatedAt: time.Now(),
+		UserId:    uuid.NewString(),
+		Role:      models.RoleUser,
 	}
-	// Store in Mongo
-	body.Password = hashedPassword
-	body.CreatedAt = time.Now()
-	body.UserId = uuid.NewString()
 
-	if err := userRepo.InsertUser(body); err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Internal server error.",
-			Error:   err.Error(),
-		})
+	if err := userRepo.InsertUser(user); err != nil {
+		return err
 	}
 
-	return c.Status(fiber.StatusOK).JSON(models.ResponseMsg{
+	return created(c, "/api/v2/users/"+user.UserId).JSON(models.ResponseMsg{
 		Message: "Create user successfully.",
 	})
 }
@@ -80,55 +67,34 @@ func Register(c *fiber.Ctx) error {
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param login body object{email=string,password=string} true "User credentials"
+// @Param login body models.LoginRequest true "User credentials"
 // @Success 200 {object} object{message=string,data=object{token=string}}
-// @Failure 400 {object} models.ResponseError
-// @Failure 401 {object} models.ResponseMsg
-// @Failure 404 {object} models.ResponseMsg
-// @Failure 500 {object} models.ResponseError
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
 // @Router /api/v1/login [post]
+// @Router /api/v2/sessions [post]
 func Login(c *fiber.Ctx) error {
-	body := &models.User{}
-	if err := c.BodyParser(body); err != nil {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseError{
-			Message: "Invalid body.",
-			Error:   err.Error(),
-		})
-	}
-	// Validate
-	if (body.Email == "") || (body.Password == "") {
-		return c.Status(fiber.ErrBadRequest.Code).JSON(models.ResponseError{
-			Message: "Invalid body.",
-			Error:   "Missing required fields.",
-		})
+	// Extract and validate body
+	body := &models.LoginRequest{}
+	if err := parseBody(c, body); err != nil {
+		return err
 	}
 	// Get user
 	userRepo := repositories.NewUserRepository()
 	user, err := userRepo.GetUserByEmail(body.Email)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to get user.",
-			Error:   err.Error(),
-		})
-	}
-	if user == nil {
-		return c.Status(fiber.ErrNotFound.Code).JSON(models.ResponseMsg{
-			Message: "Invalid email or password.",
-		})
+		return err
 	}
-	// Compare Password
-	if !utils.ComparePassword(user.Password, body.Password) {
-		return c.Status(fiber.ErrUnauthorized.Code).JSON(models.ResponseMsg{
-			Message: "Invalid email or password.",
-		})
+	// Unknown emails and wrong passwords look the same to the client, and
+	// so do accounts being deleted
+	if user == nil || user.Deletion != nil || !utils.ComparePassword(user.Password, body.Password) {
+		return apperror.Unauthorized(apperror.CodeInvalidCredentials, "Invalid email or password.")
 	}
 	// Generate token and return to client
 	token, err := utils.GenerateToken(user.Email, user.UserId)
 	if err != nil {
-		return c.Status(fiber.ErrInternalServerError.Code).JSON(models.ResponseError{
-			Message: "Failed to generate token.",
-			Error:   err.Error(),
-		})
+		return err
 	}
 	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
 		Message: "Login successfully.",
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inkinkink111/go-blog-management/apperror"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/db"
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/repositories"
+	"inkinkink111/go-blog-management/utils"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Blogs viewed since the last flush
+	viewsDirtyKey = "blog:views:dirty"
+	// Unique counters are flushed daily, they only need to outlive their day
+	viewUniquesTTL = 48 * time.Hour
+	// Views waiting to be counted, reads never wait for Redis
+	viewQueueSize = 4096
+	dateLayout    = "2006-01-02"
+	// Entries in each analytics ranking
+	analyticsTopLimit = 10
+	maxAnalyticsDays  = 365
+	// Field of a flushing hash holding daily views written to the view
+	// collection but not yet added to the blog's popularity
+	viewsPopularityField = "popularity"
+)
+
+// viewsKey holds a blog's views not flushed yet, per day and per day and
+// referrer
+func viewsKey(blogID string) string {
+	return fmt.Sprintf("blog:views:%s", blogID)
+}
+
+// viewsFlushingKey holds the views a flush took, until MongoDB has them
+func viewsFlushingKey(blogID string) string {
+	return fmt.Sprintf("blog:views:flushing:%s", blogID)
+}
+
+func viewUniquesKey(blogID, date string) string {
+	return fmt.Sprintf("blog:views:uniques:%s:%s", blogID, date)
+}
+
+func viewSeenKey(blogID, visitor string) string {
+	return fmt.Sprintf("blog:views:seen:%s:%s", blogID, visitor)
+}
+
+type pageView struct {
+	blogID   string
+	tags     []string
+	visitor  string
+	referrer string
+	at       time.Time
+}
+
+var viewQueue = make(chan pageView, viewQueueSize)
+
+// recordView queues a read of blog to be counted. Authors reading their
+// own blogs and crawlers are not counted, and views are dropped rather
+// than slowing reads down when the queue is full.
+func recordView(c *fiber.Ctx, blog *models.Blog) {
+	viewerID, _ := c.Locals("userId").(string)
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if viewerID == blog.AuthorID || isCrawler(userAgent) {
+		return
+	}
+	visitor := "u:" + viewerID
+	if viewerID == "" {
+		visitor = "a:" + utils.HashSecret(c.IP() + "|" + userAgent)[:32]
+	}
+	view := pageView{
+		blogID:   blog.BlogID,
+		tags:     blog.Tags,
+		visitor:  visitor,
+		referrer: referrerHost(c.Get(fiber.HeaderReferer)),
+		at:       time.Now(),
+	}
+	select {
+	case viewQueue <- view:
+	default:
+	}
+}
+
+func isCrawler(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	if userAgent == "" {
+		return true
+	}
+	return slices.ContainsFunc([]string{"bot", "crawl", "spider", "slurp"}, func(marker string) bool {
+		return strings.Contains(userAgent, marker)
+	})
+}
+
+// referrerHost reduces a Referer header to its host
+func referrerHost(referer string) string {
+	parsed, err := url.Parse(referer)
+	if err != nil || parsed.Hostname() == "" {
+		return models.DirectReferrer
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// countView counts a view unless the visitor already viewed the blog
+// within the dedup window
+func countView(view pageView) error {
+	ctx := context.Background()
+	first, err := db.RedisClient.SetNX(ctx, viewSeenKey(view.blogID, view.visitor), 1, config.ViewDedupWindow()).Result()
+	if err != nil || !first {
+		return err
+	}
+	date := view.at.UTC().Format(dateLayout)
+	pipe := db.RedisClient.Pipeline()
+	pipe.PFAdd(ctx, viewUniquesKey(view.blogID, date), view.visitor)
+	pipe.Expire(ctx, viewUniquesKey(view.blogID, date), viewUniquesTTL)
+	pipe.HIncrBy(ctx, viewsKey(view.blogID), date, 1)
+	pipe.HIncrBy(ctx, viewsKey(view.blogID), date+"|"+view.referrer, 1)
+	pipe.SAdd(ctx, viewsDirtyKey, view.blogID)
+	addTrendingActivity(pipe, view.blogID, view.tags, trendingViewWeight)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// dropViewCounts forgets the views of deleted blogs not flushed yet
+func dropViewCounts(blogIDs []string) {
+	keys := make([]string, 0, 2*len(blogIDs))
+	members := make([]any, len(blogIDs))
+	for i, blogID := range blogIDs {
+		keys = append(keys, viewsKey(blogID), viewsFlushingKey(blogID))
+		members[i] = blogID
+	}
+	db.RedisClient.Del(context.Background(), keys...)
+	db.RedisClient.SRem(context.Background(), viewsDirtyKey, members...)
+}
+
+// FlushViewCounts copies the views of every blog viewed since the last
+// flush to MongoDB, adding them to the blogs' popularity.
+func FlushViewCounts() error {
+	flushed := 0
+	for {
+		blogID, err := db.RedisClient.SPop(context.Background(), viewsDirtyKey).Result()
+		if errors.Is(err, redis.Nil) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := flushBlogViews(blogID); err != nil {
+			// Retry on the next flush
+			db.RedisClient.SAdd(context.Background(), viewsDirtyKey, blogID)
+			return err
+		}
+		flushed++
+	}
+	// Cached lists sort by the old popularity
+	if flushed > 0 {
+		for _, pattern := range []string{"blog:list:*", "blog:search:*"} {
+			keys, _ := db.RedisClient.Keys(context.Background(), pattern).Result()
+			if len(keys) > 0 {
+				db.RedisClient.Del(context.Background(), keys...)
+			}
+		}
+	}
+	return nil
+}
+
+// flushBlogViews moves a blog's pending views aside so new views keep
+// counting, then writes them. Views left aside by a failed flush are
+// written before taking new ones. Each field is dropped as soon as it is
+// written so a retry never adds it twice, daily views moving to the
+// popularity field until the blog has them.
+func flushBlogViews(blogID string) error {
+	ctx := context.Background()
+	pending, flushing := viewsKey(blogID), viewsFlushingKey(blogID)
+	leftover, err := db.RedisClient.Exists(ctx, flushing).Result()
+	if err != nil {
+		return err
+	}
+	if leftover == 0 {
+		exists, err := db.RedisClient.Exists(ctx, pending).Result()
+		if err != nil || exists == 0 {
+			return err
+		}
+		if err := db.RedisClient.Rename(ctx, pending, flushing).Err(); err != nil {
+			return err
+		}
+	}
+	values, err := db.RedisClient.HGetAll(ctx, flushing).Result()
+	if err != nil {
+		return err
+	}
+	viewRepo := repositories.NewViewRepository()
+	for field, value := range values {
+		if field == viewsPopularityField {
+			continue
+		}
+		count, _ := strconv.ParseInt(value, 10, 64)
+		date, referrer, byReferrer := strings.Cut(field, "|")
+		if byReferrer {
+			err = viewRepo.AddReferrerViews(blogID, date, referrer, count)
+		} else {
+			var uniques int64
+			uniques, err = db.RedisClient.PFCount(ctx, viewUniquesKey(blogID, date)).Result()
+			if err == nil {
+				err = viewRepo.AddDailyViews(blogID, date, count, uniques)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		pipe := db.RedisClient.TxPipeline()
+		pipe.HDel(ctx, flushing, field)
+		if !byReferrer {
+			pipe.HIncrBy(ctx, flushing, viewsPopularityField, count)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	total, err := db.RedisClient.HGet(ctx, flushing, viewsPopularityField).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	if total > 0 {
+		if err := repositories.NewBlogRepository().IncPopularity(blogID, total); err != nil {
+			return err
+		}
+	}
+	return db.RedisClient.Del(ctx, flushing).Err()
+}
+
+// StartViewCounter counts queued views as they come and flushes the
+// counts every interval until the process exits.
+func StartViewCounter(interval time.Duration) {
+	go func() {
+		for view := range viewQueue {
+			if err := countView(view); err != nil {
+				log.Printf("Failed to count view of blog %s: %v", view.blogID, err)
+			}
+		}
+	}()
+	go func() {
+		for range time.Tick(interval) {
+			if err := FlushViewCounts(); err != nil {
+				log.Printf("Failed to flush view counts: %v", err)
+			}
+		}
+	}()
+}
+
+// @Summary Get my analytics
+// @Description Views of my blogs per day, top referrers and top posts over the last days (UTC), optionally for one blog. Counts are flushed every VIEW_FLUSH_INTERVAL, so the latest views may be missing.
+// @Tags analytics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param days query int false "Number of days up to today" default(30)
+// @Param blog_id query string false "Only this blog"
+// @Success 200 {object} models.GetAnalyticsResponse
+// @Failure 400 {object} apperror.Problem
+// @Failure 401 {object} apperror.Problem
+// @Failure 404 {object} apperror.Problem
+// @Failure 500 {object} apperror.Problem
+// @Router /api/v1/me/analytics [get]
+// @Router /api/v2/me/analytics [get]
+func GetAnalytics(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	// Get query params
+	var errs []apperror.FieldError
+	days, err := strconv.Atoi(c.Query("days", "30"))
+	if err != nil || days < 1 || days > maxAnalyticsDays {
+		errs = append(errs, apperror.Invalid("days", fmt.Sprintf("days must be an integer between 1 and %d", maxAnalyticsDays)))
+	}
+	blogID := c.Query("blog_id", "")
+	if blogID != "" {
+		var ok bool
+		if blogID, ok = utils.NormalizeID(blogID); !ok {
+			errs = append(errs, apperror.Invalid("blog_id", "blog_id is invalid"))
+		}
+	}
+	if len(errs) > 0 {
+		return apperror.Validation("Invalid query params.", errs...)
+	}
+	// Only the author's own blogs, drafts included
+	blogRepo := repositories.NewBlogRepository()
+	blogIDs, err := blogRepo.ListBlogIDsByAuthor(userID)
+	if err != nil {
+		return err
+	}
+	if blogID != "" {
+		if !slices.Contains(blogIDs, blogID) {
+			return apperror.NotFound(apperror.CodeBlogNotFound, "Blog not found.")
+		}
+		blogIDs = []string{blogID}
+	}
+	today := time.Now().UTC()
+	analytics := models.Analytics{
+		From:          today.AddDate(0, 0, 1-days).Format(dateLayout),
+		To:            today.Format(dateLayout),
+		ViewsOverTime: []models.DailyViews{},
+		Referrers:     []models.ReferrerViews{},
+		TopPosts:      []models.PostViews{},
+	}
+	daily := []models.DailyViews{}
+	if len(blogIDs) > 0 {
+		viewRepo := repositories.NewViewRepository()
+		if daily, err = viewRepo.DailyViews(blogIDs, analytics.From, analytics.To); err != nil {
+			return err
+		}
+		if analytics.Referrers, err = viewRepo.TopReferrers(blogIDs, analytics.From, analytics.To, analyticsTopLimit); err != nil {
+			return err
+		}
+		if analytics.TopPosts, err = viewRepo.TopPosts(blogIDs, analytics.From, analytics.To, analyticsTopLimit); err != nil {
+			return err
+		}
+		if err := titlePosts(analytics.TopPosts); err != nil {
+			return err
+		}
+	}
+	// Every day of the range, with zeros for days without views
+	byDate := make(map[string]models.DailyViews, len(daily))
+	for _, day := range daily {
+		byDate[day.Date] = day
+		analytics.Views += day.Views
+	}
+	for i := days - 1; i >= 0; i-- {
+		date := today.AddDate(0, 0, -i).Format(dateLayout)
+		day, ok := byDate[date]
+		if !ok {
+			day = models.DailyViews{Date: date}
+		}
+		analytics.ViewsOverTime = append(analytics.ViewsOverTime, day)
+	}
+	return c.Status(fiber.StatusOK).JSON(models.ResponseData{
+		Message: "Get analytics successfully.",
+		Data:    analytics,
+	})
+}
+
+// titlePosts fills in the titles of ranked posts
+func titlePosts(posts []models.PostViews) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	blogIDs := make([]string, len(posts))
+	for i := range posts {
+		blogIDs[i] = posts[i].BlogID
+	}
+	blogs, err := repositories.NewBlogRepository().GetBlogSummariesByIDs(blogIDs)
+	if err != nil {
+		return err
+	}
+	titles := make(map[string]string, len(blogs))
+	for _, blog := range blogs {
+		titles[blog.BlogID] = blog.Title
+	}
+	for i := range posts {
+		posts[i].Title = titles[posts[i].BlogID]
+	}
+	return nil
+}
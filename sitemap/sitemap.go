@@ -0,0 +1,70 @@
+// Package sitemap renders sitemaps and sitemap indexes as defined by
+// sitemaps.org. Locations are expected to be absolute.
+package sitemap
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// MaxURLs is the most URLs a single sitemap may list
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is a page of the site and when it last changed
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	URLs    []location `xml:"url"`
+}
+
+type index struct {
+	XMLName  xml.Name   `xml:"sitemapindex"`
+	Xmlns    string     `xml:"xmlns,attr"`
+	Sitemaps []location `xml:"sitemap"`
+}
+
+type location struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// URLSet renders a sitemap of urls
+func URLSet(urls []URL) ([]byte, error) {
+	doc := urlSet{Xmlns: namespace, URLs: make([]location, len(urls))}
+	for i, url := range urls {
+		doc.URLs[i] = newLocation(url)
+	}
+	return marshal(doc)
+}
+
+// Index renders a sitemap index of sitemaps
+func Index(sitemaps []URL) ([]byte, error) {
+	doc := index{Xmlns: namespace, Sitemaps: make([]location, len(sitemaps))}
+	for i, sitemap := range sitemaps {
+		doc.Sitemaps[i] = newLocation(sitemap)
+	}
+	return marshal(doc)
+}
+
+func newLocation(url URL) location {
+	loc := location{Loc: url.Loc}
+	if !url.LastMod.IsZero() {
+		loc.LastMod = url.LastMod.UTC().Format(time.RFC3339)
+	}
+	return loc
+}
+
+func marshal(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,82 @@
+package sitemap_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"inkinkink111/go-blog-management/sitemap"
+)
+
+type location struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+var urls = []sitemap.URL{
+	{Loc: "https://example.com/blogs/01J9Z4A1B2/fish-chips?ref=a&b=c", LastMod: time.Date(2024, 3, 5, 9, 30, 0, 0, time.FixedZone("ICT", 7*60*60))},
+	{Loc: "https://example.com/"},
+}
+
+func TestURLSet(t *testing.T) {
+	body, err := sitemap.URLSet(urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		XMLName xml.Name   `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []location `xml:"url"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("sitemap is not well formed: %v", err)
+	}
+	want := []location{
+		{Loc: urls[0].Loc, LastMod: "2024-03-05T02:30:00Z"},
+		{Loc: urls[1].Loc},
+	}
+	if len(doc.URLs) != len(want) {
+		t.Fatalf("got %d urls, want %d", len(doc.URLs), len(want))
+	}
+	for i := range want {
+		if doc.URLs[i] != want[i] {
+			t.Errorf("url %d is %+v, want %+v", i, doc.URLs[i], want[i])
+		}
+	}
+	if !strings.Contains(string(body), "?ref=a&amp;b=c") {
+		t.Errorf("sitemap does not escape & in locations")
+	}
+	if strings.Count(string(body), "<lastmod>") != 1 {
+		t.Errorf("a URL without a modification time has a lastmod")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	sitemaps := []sitemap.URL{
+		{Loc: "https://example.com/sitemaps/1.xml", LastMod: time.Date(2024, 3, 5, 2, 30, 0, 0, time.UTC)},
+		{Loc: "https://example.com/sitemaps/2.xml", LastMod: time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)},
+	}
+	body, err := sitemap.Index(sitemaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		XMLName  xml.Name   `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []location `xml:"sitemap"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("sitemap index is not well formed: %v", err)
+	}
+	want := []location{
+		{Loc: sitemaps[0].Loc, LastMod: "2024-03-05T02:30:00Z"},
+		{Loc: sitemaps[1].Loc, LastMod: "2024-03-06T00:00:00Z"},
+	}
+	if len(doc.Sitemaps) != len(want) {
+		t.Fatalf("got %d sitemaps, want %d", len(doc.Sitemaps), len(want))
+	}
+	for i := range want {
+		if doc.Sitemaps[i] != want[i] {
+			t.Errorf("sitemap %d is %+v, want %+v", i, doc.Sitemaps[i], want[i])
+		}
+	}
+}
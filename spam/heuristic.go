@@ -0,0 +1,91 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"inkinkink111/go-blog-management/config"
+	"inkinkink111/go-blog-management/db"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Words that are never legitimate in a comment here, extended with
+// SPAM_BLOCKLIST
+var defaultBlocklist = []string{"viagra", "cialis", "casino", "payday loan", "crypto giveaway", "escort service"}
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.`)
+
+// HeuristicScorer adds up simple signals: many links, blocklisted words
+// and posting faster than a person types.
+type HeuristicScorer struct {
+	// Links allowed before each one raises the score
+	MaxLinks int
+	// Comments an author can post per RateWindow
+	MaxRate    int64
+	RateWindow time.Duration
+	// Built on first use, once the environment is loaded
+	blocklistOnce sync.Once
+	blocklist     *regexp.Regexp
+}
+
+func NewHeuristicScorer() *HeuristicScorer {
+	return &HeuristicScorer{
+		MaxLinks:   2,
+		MaxRate:    3,
+		RateWindow: time.Minute,
+	}
+}
+
+func (s *HeuristicScorer) blocklistPattern() *regexp.Regexp {
+	s.blocklistOnce.Do(func() {
+		words := slices.Concat(defaultBlocklist, config.SpamBlocklist())
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = regexp.QuoteMeta(strings.ToLower(word))
+		}
+		s.blocklist = regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	})
+	return s.blocklist
+}
+
+// Score also counts new comments towards their author's posting rate
+func (s *HeuristicScorer) Score(comment Comment) (Result, error) {
+	var result Result
+	if links := len(linkPattern.FindAllStringIndex(comment.Content, -1)); links > s.MaxLinks {
+		result.Score += 0.25 * float64(links-s.MaxLinks)
+		result.Reasons = append(result.Reasons, "links")
+	}
+	if hits := len(s.blocklistPattern().FindAllStringIndex(comment.Content, -1)); hits > 0 {
+		result.Score += 0.5 * float64(hits)
+		result.Reasons = append(result.Reasons, "blocklist")
+	}
+	if !comment.Edit {
+		rate, err := s.countPost(comment.AuthorID)
+		if err != nil {
+			return Result{}, err
+		}
+		if rate > s.MaxRate {
+			result.Score += 0.5
+			result.Reasons = append(result.Reasons, "rate")
+		}
+	}
+	result.Score = min(result.Score, 1)
+	return result, nil
+}
+
+// countPost returns how many comments the author posted in the current
+// rate window, this one included
+func (s *HeuristicScorer) countPost(authorID string) (int64, error) {
+	key := fmt.Sprintf("comment:rate:%s", authorID)
+	count, err := db.RedisClient.Incr(context.Background(), key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		db.RedisClient.Expire(context.Background(), key, s.RateWindow)
+	}
+	return count, nil
+}
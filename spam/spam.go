@@ -0,0 +1,40 @@
+// Package spam scores comments before they are published. Services use
+// Default, replace it to plug in an external classifier.
+package spam
+
+// Comment is what a scorer gets to judge
+type Comment struct {
+	AuthorID string
+	BlogID   string
+	Content  string
+	// Edit is set when a posted comment is rescored after its author
+	// changed it, which isn't a new post
+	Edit bool
+}
+
+// Result is a score between 0 (clean) and 1 (spam) with the reasons that
+// raised it, shown to moderators.
+type Result struct {
+	Score   float64
+	Reasons []string
+}
+
+type Scorer interface {
+	Score(comment Comment) (Result, error)
+}
+
+// Trainer is implemented by scorers that learn from moderator decisions
+type Trainer interface {
+	Report(comment Comment, isSpam bool) error
+}
+
+// Default is used by services
+var Default Scorer = NewHeuristicScorer()
+
+// Report passes a moderator decision on to Default if it can learn from it
+func Report(comment Comment, isSpam bool) error {
+	if trainer, ok := Default.(Trainer); ok {
+		return trainer.Report(comment, isSpam)
+	}
+	return nil
+}
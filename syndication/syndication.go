@@ -0,0 +1,29 @@
+// Package syndication renders blog feeds as RSS 2.0, Atom and JSON Feed.
+// Links are expected to be absolute.
+package syndication
+
+import "time"
+
+// Feed is a format independent feed
+type Feed struct {
+	Title       string
+	Description string
+	// HomeURL is the page the feed is about, FeedURL the feed itself
+	HomeURL string
+	FeedURL string
+	Updated time.Time
+	Items   []Item
+}
+
+// Item is one post of a feed
+type Item struct {
+	ID          string
+	URL         string
+	Title       string
+	Summary     string
+	ContentHTML string
+	Author      string
+	Tags        []string
+	Published   time.Time
+	Updated     time.Time
+}
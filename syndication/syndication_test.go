@@ -0,0 +1,214 @@
+package syndication_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"inkinkink111/go-blog-management/syndication"
+)
+
+// Feed times are in a non UTC zone, renderers must convert them
+var bangkok = time.FixedZone("ICT", 7*60*60)
+
+var feed = syndication.Feed{
+	Title:       "Fish & <Chips>",
+	Description: "Posts about fish & chips",
+	HomeURL:     "https://example.com/?tag=fish&sort=new",
+	FeedURL:     "https://example.com/feed.xml?tag=fish&sort=new",
+	Updated:     time.Date(2024, 3, 5, 9, 30, 0, 0, bangkok),
+	Items: []syndication.Item{
+		{
+			ID:          "https://example.com/blogs/01J9Z4A1B2",
+			URL:         "https://example.com/blogs/01J9Z4A1B2/fish?ref=a&b=c",
+			Title:       `Frying "fish" & <chips>`,
+			Summary:     "Hot oil & patience",
+			ContentHTML: "<p>Hot oil &amp; patience</p>",
+			Author:      "Ink",
+			Tags:        []string{"cooking", "fish"},
+			Published:   time.Date(2024, 3, 1, 8, 0, 0, 0, bangkok),
+			Updated:     time.Date(2024, 3, 5, 9, 30, 0, 0, bangkok),
+		},
+	},
+}
+
+func TestRSS(t *testing.T) {
+	body, err := syndication.RSS(feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Channel struct {
+			Title string `xml:"title"`
+			// The atom:link to the feed itself shares the local name
+			Links         []string `xml:"link"`
+			LastBuildDate string   `xml:"lastBuildDate"`
+			Items         []struct {
+				Title   string `xml:"title"`
+				Link    string `xml:"link"`
+				PubDate string `xml:"pubDate"`
+				Content string `xml:"encoded"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("RSS is not well formed: %v", err)
+	}
+	if doc.Channel.Title != feed.Title || len(doc.Channel.Links) == 0 || doc.Channel.Links[0] != feed.HomeURL {
+		t.Errorf("channel is %q %q, want %q %q", doc.Channel.Title, doc.Channel.Links, feed.Title, feed.HomeURL)
+	}
+	if want := "Tue, 05 Mar 2024 02:30:00 +0000"; doc.Channel.LastBuildDate != want {
+		t.Errorf("lastBuildDate is %q, want %q", doc.Channel.LastBuildDate, want)
+	}
+	if len(doc.Channel.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(doc.Channel.Items))
+	}
+	item := doc.Channel.Items[0]
+	if want := "Fri, 01 Mar 2024 01:00:00 +0000"; item.PubDate != want {
+		t.Errorf("pubDate is %q, want %q", item.PubDate, want)
+	}
+	if pubDate, err := time.Parse(time.RFC1123Z, item.PubDate); err != nil || !pubDate.Equal(feed.Items[0].Published) {
+		t.Errorf("pubDate %q does not parse back as RFC 1123: %v", item.PubDate, err)
+	}
+	if item.Title != feed.Items[0].Title || item.Link != feed.Items[0].URL {
+		t.Errorf("item is %q %q, want %q %q", item.Title, item.Link, feed.Items[0].Title, feed.Items[0].URL)
+	}
+	if item.Content != feed.Items[0].ContentHTML {
+		t.Errorf("content:encoded is %q, want %q", item.Content, feed.Items[0].ContentHTML)
+	}
+	for _, raw := range []string{"Fish &amp; &lt;Chips&gt;", "?tag=fish&amp;sort=new", "?ref=a&amp;b=c"} {
+		if !strings.Contains(string(body), raw) {
+			t.Errorf("RSS does not contain the escaped %q", raw)
+		}
+	}
+}
+
+func TestRSSWithoutUpdated(t *testing.T) {
+	empty := syndication.Feed{Title: "Empty", HomeURL: "https://example.com/"}
+	body, err := syndication.RSS(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "lastBuildDate") {
+		t.Errorf("a feed without an update time has a lastBuildDate")
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, err := syndication.Atom(feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Title   string   `xml:"title"`
+		Updated string   `xml:"updated"`
+		Links   []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Entries []struct {
+			Title     string `xml:"title"`
+			ID        string `xml:"id"`
+			Published string `xml:"published"`
+			Updated   string `xml:"updated"`
+			Link      struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Atom is not well formed: %v", err)
+	}
+	if doc.Title != feed.Title {
+		t.Errorf("title is %q, want %q", doc.Title, feed.Title)
+	}
+	if want := "2024-03-05T02:30:00Z"; doc.Updated != want {
+		t.Errorf("updated is %q, want %q", doc.Updated, want)
+	}
+	links := map[string]string{}
+	for _, link := range doc.Links {
+		links[link.Rel] = link.Href
+	}
+	if links["self"] != feed.FeedURL || links["alternate"] != feed.HomeURL {
+		t.Errorf("links are %v, want self %q and alternate %q", links, feed.FeedURL, feed.HomeURL)
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(doc.Entries))
+	}
+	entry := doc.Entries[0]
+	if entry.Published != "2024-03-01T01:00:00Z" || entry.Updated != "2024-03-05T02:30:00Z" {
+		t.Errorf("entry dates are %q %q, want RFC 3339 in UTC", entry.Published, entry.Updated)
+	}
+	if entry.Title != feed.Items[0].Title || entry.ID != feed.Items[0].ID || entry.Link.Href != feed.Items[0].URL {
+		t.Errorf("entry is %q %q %q", entry.Title, entry.ID, entry.Link.Href)
+	}
+	for _, raw := range []string{"Fish &amp; &lt;Chips&gt;", "?tag=fish&amp;sort=new", "?ref=a&amp;b=c"} {
+		if !strings.Contains(string(body), raw) {
+			t.Errorf("Atom does not contain the escaped %q", raw)
+		}
+	}
+}
+
+func TestJSONFeed(t *testing.T) {
+	body, err := syndication.JSONFeed(feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"version":       "https://jsonfeed.org/version/1.1",
+		"title":         feed.Title,
+		"home_page_url": feed.HomeURL,
+		"feed_url":      feed.FeedURL,
+		"description":   feed.Description,
+	}
+	for field, value := range want {
+		if doc[field] != value {
+			t.Errorf("%s is %v, want %v", field, doc[field], value)
+		}
+	}
+	items, _ := doc["items"].([]any)
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+	item := items[0].(map[string]any)
+	wantItem := map[string]any{
+		"id":             feed.Items[0].ID,
+		"url":            feed.Items[0].URL,
+		"title":          feed.Items[0].Title,
+		"content_html":   feed.Items[0].ContentHTML,
+		"summary":        feed.Items[0].Summary,
+		"date_published": "2024-03-01T01:00:00Z",
+		"date_modified":  "2024-03-05T02:30:00Z",
+	}
+	for field, value := range wantItem {
+		if item[field] != value {
+			t.Errorf("item %s is %v, want %v", field, item[field], value)
+		}
+	}
+	authors, _ := item["authors"].([]any)
+	if len(authors) != 1 || authors[0].(map[string]any)["name"] != "Ink" {
+		t.Errorf("authors are %v, want Ink", item["authors"])
+	}
+	if tags, _ := item["tags"].([]any); len(tags) != 2 {
+		t.Errorf("tags are %v, want cooking and fish", item["tags"])
+	}
+}
+
+func TestJSONFeedWithoutAuthor(t *testing.T) {
+	anonymous := feed
+	anonymous.Items = []syndication.Item{{ID: "a", URL: "https://example.com/a", Title: "A"}}
+	body, err := syndication.JSONFeed(anonymous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), `"authors"`) {
+		t.Errorf("an item without an author lists authors")
+	}
+}
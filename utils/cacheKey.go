@@ -4,27 +4,70 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"inkinkink111/go-blog-management/models"
 )
 
-func GenerateCacheKey(page, limit string, tags []string) string {
+func GenerateCacheKey(query models.BlogListQuery) string {
 	var keyParts []string
 	// Base key
 	keyParts = append(keyParts, "blog:list")
 	// Add pagination
-	keyParts = append(keyParts, fmt.Sprintf("page:%s", page))
-	keyParts = append(keyParts, fmt.Sprintf("limit:%s", limit))
+	if query.Cursor != nil {
+		keyParts = append(keyParts, fmt.Sprintf("cursor:%s", EncodeCursor(*query.Cursor)))
+	} else {
+		keyParts = append(keyParts, fmt.Sprintf("page:%d", query.Page))
+	}
+	keyParts = append(keyParts, fmt.Sprintf("limit:%d", query.Limit))
+	keyParts = append(keyParts, fmt.Sprintf("sort:%s", query.Sort))
+	if query.AuthorID != "" {
+		keyParts = append(keyParts, fmt.Sprintf("author:%s", query.AuthorID))
+	}
 	// Add tags
-	if len(tags) > 0 {
-		// Sort tags for consistent cache keys
-		sortedTags := make([]string, len(tags))
-		copy(sortedTags, tags)
-		sort.Strings(sortedTags)
-
-		// Join tags with a delimiter
-		tagsString := strings.Join(sortedTags, ",")
-		keyParts = append(keyParts, fmt.Sprintf("tags:%s", tagsString))
+	if len(query.Tags) > 0 {
+		keyParts = append(keyParts, fmt.Sprintf("tag_mode:%s", query.TagMode))
+		keyParts = append(keyParts, fmt.Sprintf("tags:%s", joinSorted(query.Tags)))
+	}
+	if len(query.ExcludedTags) > 0 {
+		keyParts = append(keyParts, fmt.Sprintf("exclude:%s", joinSorted(query.ExcludedTags)))
+	}
+
+	// Summaries and full blogs are cached separately
+	if query.WithContent {
+		keyParts = append(keyParts, "content")
+	}
+	if query.EmbedAuthor {
+		keyParts = append(keyParts, "embed:author")
 	}
 
 	// Join all parts
 	return strings.Join(keyParts, ":")
 }
+
+func GenerateSearchCacheKey(query string, page, limit int) string {
+	return strings.Join([]string{
+		"blog:search",
+		fmt.Sprintf("page:%d", page),
+		fmt.Sprintf("limit:%d", limit),
+		fmt.Sprintf("q:%s", query),
+	}, ":")
+}
+
+// GenerateCommentsCacheKey keys a page of a blog's comment threads. All
+// pages of a blog share the blog:comments:<id> prefix.
+func GenerateCommentsCacheKey(blogID string, page, limit int) string {
+	return strings.Join([]string{
+		"blog:comments",
+		blogID,
+		fmt.Sprintf("page:%d", page),
+		fmt.Sprintf("limit:%d", limit),
+	}, ":")
+}
+
+// joinSorted sorts values for consistent cache keys and joins them
+func joinSorted(values []string) string {
+	sorted := make([]string, len(values))
+	copy(sorted, values)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
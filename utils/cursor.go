@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"inkinkink111/go-blog-management/models"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor turns a list cursor into an opaque URL-safe token.
+func EncodeCursor(cursor models.ListCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(token string) (*models.ListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor models.ListCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.BlogID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,63 @@
+package utils_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"inkinkink111/go-blog-management/models"
+	"inkinkink111/go-blog-management/utils"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 9, 17, 4, 5, 123456789, time.FixedZone("CET", 3600))
+	cursors := []models.ListCursor{
+		{Sort: models.SortNewest, Time: at, BlogID: "b1"},
+		{Sort: models.SortUpdated, Time: at, BlogID: "b2", Before: true},
+		{Sort: models.SortTitle, Title: `Ünïcode & "quotes" / slashes`, BlogID: "b3"},
+		{Sort: models.SortPopularity, Popularity: 1 << 40, BlogID: "b4"},
+		{Sort: models.SortLikes, Likes: 7, BlogID: "b5", Before: true},
+		{Sort: models.SortTitle, Title: "", BlogID: "b6"},
+	}
+	for _, want := range cursors {
+		token := utils.EncodeCursor(want)
+		if strings.ContainsAny(token, "+/=") {
+			t.Errorf("token %q is not URL-safe", token)
+		}
+		got, err := utils.DecodeCursor(token)
+		if err != nil {
+			t.Errorf("%+v: %v", want, err)
+			continue
+		}
+		if got.Sort != want.Sort || !got.Time.Equal(want.Time) || got.Title != want.Title ||
+			got.Popularity != want.Popularity || got.Likes != want.Likes ||
+			got.BlogID != want.BlogID || got.Before != want.Before {
+			t.Errorf("decoded %+v, want %+v", *got, want)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsInvalidTokens(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	tokens := map[string]string{
+		"empty":          "",
+		"bad base64":     "not a cursor!",
+		"padded base64":  base64.URLEncoding.EncodeToString([]byte(`{"s":"title","id":"b1"}`)),
+		"not json":       encode("b1"),
+		"json array":     encode(`["b1"]`),
+		"missing blog":   encode(`{"s":"title","n":"Go"}`),
+		"empty blog":     encode(`{"s":"title","id":""}`),
+		"wrong type":     encode(`{"s":"popularity","p":"high","id":"b1"}`),
+		"truncated json": encode(`{"s":"title","id":"b1"`),
+	}
+	for name, token := range tokens {
+		cursor, err := utils.DecodeCursor(token)
+		if !errors.Is(err, utils.ErrInvalidCursor) {
+			t.Errorf("%s: got %+v, %v, want ErrInvalidCursor", name, cursor, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BlogETag is the entity tag of a blog at a given version.
+func BlogETag(version int64) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// BlogBodyETag is the entity tag of a blog as sent to a reader: its
+// version, which If-Match checks, and a hash of the body, which also
+// changes with live counts and the reader's own reactions.
+func BlogBodyETag(version int64, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"v%d-%s"`, version, hex.EncodeToString(sum[:8]))
+}
+
+// An entity tag sent for a blog, by a write or by a read
+var blogETagPattern = regexp.MustCompile(`^"v([0-9]+)(?:-[0-9a-f]{16})?"$`)
+
+// MatchBlogVersion reports whether an If-Match header value names a blog's
+// version, with the ETag of a write or of a read. If-Match uses the strong
+// comparison, so weak and malformed validators never match.
+func MatchBlogVersion(header string, version int64) bool {
+	want := strconv.FormatInt(version, 10)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if match := blogETagPattern.FindStringSubmatch(candidate); match != nil && match[1] == want {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchETag reports whether an If-None-Match header value matches etag.
+// If-None-Match uses the weak comparison, a weak validator is equal to its
+// strong form.
+func MatchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
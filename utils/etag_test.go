@@ -0,0 +1,85 @@
+package utils_test
+
+import (
+	"regexp"
+	"testing"
+
+	"inkinkink111/go-blog-management/utils"
+)
+
+// MatchETag checks If-None-Match, where weak validators match
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		want   bool
+	}{
+		{`"v3"`, `"v3"`, true},
+		{`"v3"`, `"v4"`, false},
+		{`W/"v3"`, `"v3"`, true},
+		{`"v3"`, `W/"v3"`, true},
+		{`*`, `"v3"`, true},
+		{`"v1", "v2" ,"v3"`, `"v3"`, true},
+		{`"v1", W/"v3"`, `"v3"`, true},
+		{`"v1", "v2"`, `"v3"`, false},
+		{`v3`, `"v3"`, false},
+		{``, `"v3"`, false},
+	}
+	for _, tt := range tests {
+		if got := utils.MatchETag(tt.header, tt.etag); got != tt.want {
+			t.Errorf("MatchETag(%q, %q) is %v, want %v", tt.header, tt.etag, got, tt.want)
+		}
+	}
+}
+
+func TestBlogBodyETag(t *testing.T) {
+	body := []byte(`{"blog_id":"b1","like_count":1}`)
+	etag := utils.BlogBodyETag(3, body)
+	if !regexp.MustCompile(`^"v3-[0-9a-f]{16}"$`).MatchString(etag) {
+		t.Errorf("ETag %s is not the version and a body hash", etag)
+	}
+	if again := utils.BlogBodyETag(3, body); again != etag {
+		t.Errorf("same body gave %s then %s", etag, again)
+	}
+	if other := utils.BlogBodyETag(3, []byte(`{"blog_id":"b1","like_count":2}`)); other == etag {
+		t.Errorf("a changed body kept the ETag %s", etag)
+	}
+	if other := utils.BlogBodyETag(4, body); other == etag {
+		t.Errorf("a new version kept the ETag %s", etag)
+	}
+	if !utils.MatchETag(etag, etag) || !utils.MatchETag("W/"+etag, etag) {
+		t.Errorf("ETag %s does not match itself", etag)
+	}
+}
+
+// MatchBlogVersion checks If-Match, where only strong validators match
+func TestMatchBlogVersion(t *testing.T) {
+	readETag := utils.BlogBodyETag(3, []byte("{}"))
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{utils.BlogETag(3), true},
+		{readETag, true},
+		{`*`, true},
+		{`"v1", ` + readETag, true},
+		{utils.BlogETag(2), false},
+		{utils.BlogBodyETag(2, []byte("{}")), false},
+		{"W/" + readETag, false},
+		{`W/"v3"`, false},
+		{`v3`, false},
+		{`v3-0123456789abcdef`, false},
+		{`"v3-garbage"`, false},
+		{`"v3-0123456789ABCDEF"`, false},
+		{`"v3-0123456789abcdef0"`, false},
+		{`"v31"`, false},
+		{`"v31-0123456789abcdef"`, false},
+		{`"3"`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := utils.MatchBlogVersion(tt.header, 3); got != tt.want {
+			t.Errorf("MatchBlogVersion(%q, 3) is %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
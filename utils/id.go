@@ -1,18 +1,75 @@
 package utils
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
 	"time"
 )
 
+// Crockford base32 alphabet used for blog IDs (no I, L, O, U)
+const idCharset = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+const (
+	idLength       = 26
+	legacyIDLength = 8
+)
+
+// GenerateID returns a ULID-style identifier: 48 bits of millisecond
+// timestamp followed by 80 bits of crypto randomness, encoded as 26
+// Crockford base32 characters so IDs sort by creation time.
 func GenerateID() string {
-	// Generate a ID with number and char
-	const length = 8
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	seededRand := rand.New(rand.NewSource(time.Now().UnixNano()))
-	b := make([]byte, length)
-	for i := range length {
-		b[i] = charset[seededRand.Intn(len(charset))]
+	var b [16]byte
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+	copy(b[:6], ts[2:])
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	// Encode from least significant bits, 128 bits = 25*5 + 3
+	out := make([]byte, idLength)
+	var acc uint
+	bits := 0
+	i := idLength - 1
+	for j := len(b) - 1; j >= 0; j-- {
+		acc |= uint(b[j]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[i] = idCharset[acc&31]
+			acc >>= 5
+			bits -= 5
+			i--
+		}
+	}
+	out[0] = idCharset[acc&31]
+	return string(out)
+}
+
+// NormalizeID validates a blog ID in either the current 26 character
+// format or the legacy 8 character alphanumeric format. New IDs are
+// case-insensitive and are upper-cased, legacy IDs are returned as is.
+func NormalizeID(id string) (string, bool) {
+	switch len(id) {
+	case idLength:
+		upper := strings.ToUpper(id)
+		// First char only carries 3 bits
+		if upper[0] > '7' {
+			return "", false
+		}
+		for i := 0; i < len(upper); i++ {
+			if strings.IndexByte(idCharset, upper[i]) < 0 {
+				return "", false
+			}
+		}
+		return upper, true
+	case legacyIDLength:
+		for i := 0; i < len(id); i++ {
+			c := id[i]
+			if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+				return "", false
+			}
+		}
+		return id, true
 	}
-	return string(b)
+	return "", false
 }
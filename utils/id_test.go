@@ -0,0 +1,76 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"inkinkink111/go-blog-management/utils"
+)
+
+func TestGenerateID(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id := utils.GenerateID()
+		if len(id) != 26 {
+			t.Fatalf("%s is %d characters long, want 26", id, len(id))
+		}
+		if id[0] > '7' {
+			t.Fatalf("%s starts above 7, more than 128 bits", id)
+		}
+		if normalized, ok := utils.NormalizeID(id); !ok || normalized != id {
+			t.Fatalf("NormalizeID(%s) is %q, %v", id, normalized, ok)
+		}
+		if seen[id] {
+			t.Fatalf("%s was generated twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+// The timestamp comes first, so ids of later milliseconds sort after
+func TestGenerateIDSortsByTime(t *testing.T) {
+	first := utils.GenerateID()
+	time.Sleep(2 * time.Millisecond)
+	second := utils.GenerateID()
+	if second <= first {
+		t.Errorf("%s was generated after %s but sorts before it", second, first)
+	}
+}
+
+func TestNormalizeID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want string
+		ok   bool
+	}{
+		{"current", "01J9Z3T6Q8X4V2M7N5K0R1B3CD", "01J9Z3T6Q8X4V2M7N5K0R1B3CD", true},
+		{"lower case", "01j9z3t6q8x4v2m7n5k0r1b3cd", "01J9Z3T6Q8X4V2M7N5K0R1B3CD", true},
+		{"highest first character", "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", true},
+		{"first character overflows", "8ZZZZZZZZZZZZZZZZZZZZZZZZZ", "", false},
+		{"letter I", "01J9Z3T6Q8X4V2M7N5K0R1B3CI", "", false},
+		{"letter L", "01J9Z3T6Q8X4V2M7N5K0R1B3CL", "", false},
+		{"letter O", "01J9Z3T6Q8X4V2M7N5K0R1B3CO", "", false},
+		{"letter U", "01J9Z3T6Q8X4V2M7N5K0R1B3CU", "", false},
+		{"symbol", "01J9Z3T6Q8X4V2M7N5K0R1B3C-", "", false},
+		{"too short", "01J9Z3T6Q8X4V2M7N5K0R1B3C", "", false},
+		{"too long", "01J9Z3T6Q8X4V2M7N5K0R1B3CDE", "", false},
+		{"legacy", "aB3dE9xZ", "aB3dE9xZ", true},
+		{"legacy keeps case", "abcdefgh", "abcdefgh", true},
+		{"legacy with symbol", "aB3dE9x-", "", false},
+		{"legacy with space", "aB3 E9xZ", "", false},
+		{"legacy non-ascii", "aB3dE9é", "", false},
+		{"empty", "", "", false},
+		{"path traversal", "../../etc", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := utils.NormalizeID(tt.id)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: NormalizeID(%q) is %q, %v, want %q, %v", tt.name, tt.id, got, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := utils.NormalizeID(strings.Repeat("Z", 26)); ok {
+		t.Errorf("an id above 128 bits was accepted")
+	}
+}
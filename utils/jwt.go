@@ -8,6 +8,9 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenLifetime is how long an access token is accepted
+const TokenLifetime = 8 * time.Hour
+
 type CustomClaims struct {
 	Email  string `json:"email"`
 	UserId string `json:"userId"`
@@ -18,13 +21,16 @@ func GenerateToken(email string, userId string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"email":  email,
 		"userId": userId,
-		"exp":    time.Now().Add(time.Hour * 8).Unix(),
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(TokenLifetime).Unix(),
 	})
 
 	return token.SignedString([]byte(os.Getenv("JWT_SECRET_KEY")))
 }
 
-func VerifyToken(token string) (string, error) {
+// VerifyToken returns the user id of a valid token and when it was issued.
+// Tokens from before issue times were recorded have a zero issuedAt.
+func VerifyToken(token string) (string, time.Time, error) {
 	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
 		_, ok := token.Method.(*jwt.SigningMethodHMAC)
 
@@ -36,22 +42,27 @@ func VerifyToken(token string) (string, error) {
 	})
 
 	if err != nil {
-		return "", errors.New("could not parse token")
+		return "", time.Time{}, errors.New("could not parse token")
 	}
 
 	isTokenValid := parsedToken.Valid
 
 	if !isTokenValid {
-		return "", errors.New("invalid token")
+		return "", time.Time{}, errors.New("invalid token")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 
 	if !ok {
-		return "", errors.New("invalid token claims")
+		return "", time.Time{}, errors.New("invalid token claims")
 	}
 
-	userId := claims["userId"].(string)
+	userId, _ := claims["userId"].(string)
+
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
+	}
 
-	return userId, nil
+	return userId, issuedAt, nil
 }
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"inkinkink111/go-blog-management/models"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+var markdown = goldmark.New(
+	// CommonMark + GFM tables, task lists, strikethrough and autolinks
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	// Raw HTML is passed through and cleaned by the sanitizer below
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+var htmlPolicy = newHTMLPolicy()
+
+func newHTMLPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Heading anchors used by the table of contents
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	// Fenced code language hints
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9_+-]+$`)).OnElements("code")
+	// GFM task list checkboxes
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+// RenderMarkdown renders Markdown source to sanitized HTML and builds a
+// table of contents from its headings.
+func RenderMarkdown(source string) (string, []models.TOCEntry, error) {
+	src := []byte(source)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+	// Collect headings
+	toc := []models.TOCEntry{}
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		anchor, _ := heading.AttributeString("id")
+		anchorBytes, _ := anchor.([]byte)
+		toc = append(toc, models.TOCEntry{
+			Level:  heading.Level,
+			Title:  strings.TrimSpace(plainText(heading, src)),
+			Anchor: string(anchorBytes),
+		})
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	// Render and sanitize
+	var buf bytes.Buffer
+	if err := markdown.Renderer().Render(&buf, src, doc); err != nil {
+		return "", nil, err
+	}
+	return htmlPolicy.Sanitize(buf.String()), toc, nil
+}
+
+// plainText concatenates the text segments below n, dropping markup.
+func plainText(n ast.Node, src []byte) string {
+	var sb strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch t := c.(type) {
+		case *ast.Text:
+			sb.Write(t.Segment.Value(src))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(t.Value)
+		case *ast.RawHTML:
+			// Skip inline tags
+		default:
+			sb.WriteString(plainText(c, src))
+		}
+	}
+	return sb.String()
+}
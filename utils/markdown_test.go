@@ -0,0 +1,91 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+
+	"inkinkink111/go-blog-management/utils"
+)
+
+func TestRenderMarkdownStripsUnsafeHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		forbidden []string
+	}{
+		{"script tag", "Hi <script>alert(1)</script>", []string{"<script", "alert(1)"}},
+		{"event handler", `<img src="/a.png" onerror="alert(1)">`, []string{"onerror", "alert(1)"}},
+		{"event handler on link", `<a href="/a" onclick="alert(1)">a</a>`, []string{"onclick"}},
+		{"javascript link", "[click](javascript:alert(1))", []string{"javascript:"}},
+		{"javascript href", `<a href="javascript:alert(1)">a</a>`, []string{"javascript:"}},
+		{"mixed case scheme", `<a href="JaVaScRiPt:alert(1)">a</a>`, []string{"alert(1)"}},
+		{"data uri", `<a href="data:text/html;base64,PHNjcmlwdD4=">a</a>`, []string{"data:"}},
+		{"iframe", `<iframe src="https://example.com"></iframe>`, []string{"<iframe"}},
+		{"style tag", "<style>body{display:none}</style>", []string{"<style", "display:none"}},
+		{"style attribute", `<p style="position:fixed">a</p>`, []string{"style="}},
+		{"heading id injection", `<h2 id="x&quot; onmouseover=&quot;alert(1)">a</h2>`, []string{`onmouseover="`}},
+		{"code class injection", `<code class="x onclick">a</code>`, []string{"onclick"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, _, err := utils.RenderMarkdown(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, forbidden := range tt.forbidden {
+				if strings.Contains(strings.ToLower(html), strings.ToLower(forbidden)) {
+					t.Errorf("rendered %q contains %q: %s", tt.source, forbidden, html)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownKeepsSafeMarkup(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"heading anchor", "## Getting started", `<h2 id="getting-started">`},
+		{"code language", "```go\nfmt.Println()\n```", `<code class="language-go">`},
+		{"link", "[docs](https://example.com)", `href="https://example.com"`},
+		{"task list", "- [x] done", `type="checkbox"`},
+		{"table", "| a |\n|---|\n| 1 |", "<table>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, _, err := utils.RenderMarkdown(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(html, tt.want) {
+				t.Errorf("rendered %q is missing %q: %s", tt.source, tt.want, html)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownBuildsTOC(t *testing.T) {
+	_, toc, err := utils.RenderMarkdown("# Title\n\ntext\n\n## Part *one*\n\n### Detail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		level  int
+		title  string
+		anchor string
+	}{
+		{1, "Title", "title"},
+		{2, "Part one", "part-one"},
+		{3, "Detail", "detail"},
+	}
+	if len(toc) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(toc), len(want), toc)
+	}
+	for i, entry := range toc {
+		if entry.Level != want[i].level || entry.Title != want[i].title || entry.Anchor != want[i].anchor {
+			t.Errorf("entry %d is %+v, want %+v", i, entry, want[i])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"html"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+const (
+	excerptLength  = 200
+	wordsPerMinute = 200
+)
+
+var stripPolicy = bluemonday.StrictPolicy()
+
+// SummarizeContent derives the excerpt, word count and estimated reading
+// time in minutes from rendered blog HTML.
+func SummarizeContent(contentHTML string) (string, int, int) {
+	words := strings.Fields(PlainText(contentHTML))
+	wordCount := len(words)
+	readingTime := int(math.Ceil(float64(wordCount) / wordsPerMinute))
+	if readingTime < 1 {
+		readingTime = 1
+	}
+	// Cut the excerpt on a word boundary, or inside a first word too
+	// long for it (e.g. a URL)
+	var sb strings.Builder
+	for i, word := range words {
+		if utf8.RuneCountInString(sb.String())+utf8.RuneCountInString(word)+1 > excerptLength {
+			if i == 0 {
+				sb.WriteString(string([]rune(word)[:excerptLength-1]))
+			}
+			sb.WriteString("…")
+			break
+		}
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(word)
+	}
+	return sb.String(), wordCount, readingTime
+}
+
+// PlainText strips all markup from rendered HTML.
+func PlainText(contentHTML string) string {
+	// Keep block boundaries as spaces so words don't run together
+	plain := strings.NewReplacer("<", " <", ">", "> ").Replace(contentHTML)
+	plain = html.UnescapeString(stripPolicy.Sanitize(plain))
+	return strings.Join(strings.Fields(plain), " ")
+}
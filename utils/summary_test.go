@@ -0,0 +1,40 @@
+package utils_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"inkinkink111/go-blog-management/utils"
+)
+
+func TestSummarizeContent(t *testing.T) {
+	longURL := "https://example.com/" + strings.Repeat("ü", 300)
+	tests := []struct {
+		name        string
+		html        string
+		excerpt     string
+		wordCount   int
+		readingTime int
+	}{
+		{"empty", "", "", 0, 1},
+		{"markup stripped", "<h1>Hello</h1><p>big <em>world</em> &amp; more</p>", "Hello big world & more", 5, 1},
+		{"exactly full", "<p>" + strings.Repeat("a", 199) + "</p>", strings.Repeat("a", 199), 1, 1},
+		{"cut on a word", "<p>" + strings.Repeat("word ", 60) + "</p>", strings.TrimSpace(strings.Repeat("word ", 40)) + "…", 60, 1},
+		// A first word longer than the excerpt is cut on a rune boundary
+		{"long first word", "<p>" + longURL + " after</p>", longURL[:len("https://example.com/")] + strings.Repeat("ü", 179) + "…", 2, 1},
+		{"reading time", "<p>" + strings.Repeat("w ", 401) + "</p>", strings.TrimSpace(strings.Repeat("w ", 100)) + "…", 401, 3},
+	}
+	for _, tt := range tests {
+		excerpt, wordCount, readingTime := utils.SummarizeContent(tt.html)
+		if excerpt != tt.excerpt {
+			t.Errorf("%s: excerpt is %q, want %q", tt.name, excerpt, tt.excerpt)
+		}
+		if wordCount != tt.wordCount || readingTime != tt.readingTime {
+			t.Errorf("%s: got %d words and %d minutes, want %d and %d", tt.name, wordCount, readingTime, tt.wordCount, tt.readingTime)
+		}
+		if !utf8.ValidString(excerpt) || utf8.RuneCountInString(excerpt) > 200 {
+			t.Errorf("%s: excerpt is not valid UTF-8 of at most 200 runes", tt.name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"strings"
+)
+
+// NormalizeTag lowercases a tag and joins its words with hyphens, so
+// "Go Lang" and "go-lang" are the same tag.
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	// Leading "-" is reserved for excluded tags in filters
+	tag = strings.TrimLeft(tag, "-#")
+	return strings.Join(strings.Fields(tag), "-")
+}
+
+// NormalizeTags normalizes every tag, dropping empty ones and duplicates.
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// ParseTagFilter splits a comma-separated tag filter into included and
+// excluded ("-golang") tags.
+func ParseTagFilter(filter string) ([]string, []string) {
+	var included, excluded []string
+	for _, tag := range strings.Split(filter, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "-") {
+			excluded = append(excluded, tag)
+		} else {
+			included = append(included, tag)
+		}
+	}
+	return NormalizeTags(included), NormalizeTags(excluded)
+}
@@ -0,0 +1,79 @@
+package utils_test
+
+import (
+	"slices"
+	"testing"
+
+	"inkinkink111/go-blog-management/utils"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"golang", "golang"},
+		{"GoLang", "golang"},
+		{"  Go Lang  ", "go-lang"},
+		{"go   lang\tweb", "go-lang-web"},
+		{"go-lang", "go-lang"},
+		{"node.js", "node.js"},
+		{"C++", "c++"},
+		{"#golang", "golang"},
+		// A leading "-" marks an exclusion, never part of the tag
+		{"-golang", "golang"},
+		{"--#Go", "go"},
+		{"ÜBER", "über"},
+		{"   ", ""},
+		{"-", ""},
+	}
+	for _, tt := range tests {
+		if got := utils.NormalizeTag(tt.tag); got != tt.want {
+			t.Errorf("NormalizeTag(%q) is %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{nil, []string{}},
+		{[]string{"Go", "go", " GO "}, []string{"go"}},
+		{[]string{"Go Lang", "go-lang", "web"}, []string{"go-lang", "web"}},
+		{[]string{"", "  ", "#", "rust"}, []string{"rust"}},
+		// First occurrence keeps its place
+		{[]string{"web", "Go", "web", "go"}, []string{"web", "go"}},
+	}
+	for _, tt := range tests {
+		if got := utils.NormalizeTags(tt.tags); !slices.Equal(got, tt.want) || got == nil {
+			t.Errorf("NormalizeTags(%q) is %q, want %q", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		filter   string
+		included []string
+		excluded []string
+	}{
+		{"", []string{}, []string{}},
+		{"go", []string{"go"}, []string{}},
+		{"-golang", []string{}, []string{"golang"}},
+		{"Go, web ,-Golang", []string{"go", "web"}, []string{"golang"}},
+		{"go,GO,-java,-Java", []string{"go"}, []string{"java"}},
+		{"Go Lang, -Node JS", []string{"go-lang"}, []string{"node-js"}},
+		{"go,,  ,-", []string{"go"}, []string{}},
+		// Excluding a tag that is also included keeps both, the query
+		// then matches nothing
+		{"go,-go", []string{"go"}, []string{"go"}},
+	}
+	for _, tt := range tests {
+		included, excluded := utils.ParseTagFilter(tt.filter)
+		if !slices.Equal(included, tt.included) || !slices.Equal(excluded, tt.excluded) {
+			t.Errorf("ParseTagFilter(%q) is %q, %q, want %q, %q", tt.filter, included, excluded, tt.included, tt.excluded)
+		}
+	}
+}